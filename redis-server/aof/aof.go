@@ -0,0 +1,161 @@
+// Package aof implements append-only-file persistence: a Reader that
+// replays a log of RESP commands, and a Writer that appends to one (and can
+// be pointed at a freshly rewritten file by BGREWRITEAOF without losing
+// anything written concurrently).
+package aof
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/madmecodes/go_codeCrafters/redis-server/resp"
+)
+
+// Reader replays the RESP commands recorded in an AOF file.
+type Reader struct {
+	path string
+}
+
+func NewReader(dir, filename string) *Reader {
+	return &Reader{path: filepath.Join(dir, filename)}
+}
+
+// Read returns every command in the file, in the order they were appended.
+// It returns (nil, nil) if the file does not exist yet.
+func (r *Reader) Read() ([][]string, error) {
+	file, err := os.Open(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open AOF file: %v", err)
+	}
+	defer file.Close()
+
+	decoder := resp.NewDecoder(bufio.NewReader(file))
+	var commands [][]string
+	for {
+		value, err := decoder.Decode()
+		if err != nil {
+			if err == io.EOF {
+				return commands, nil
+			}
+			return nil, fmt.Errorf("failed to decode AOF command: %v", err)
+		}
+		commands = append(commands, value.StringElements())
+	}
+}
+
+// Writer appends RESP-encoded commands to an AOF file.
+type Writer struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	bw        *bufio.Writer
+	fsync     string
+	rewriting bool
+	queue     [][]byte
+}
+
+// NewWriter opens (creating if necessary) dir/filename for appending.
+// fsync selects when writes hit disk: "always" fsyncs every Append, "no"
+// never fsyncs explicitly, and "everysec" relies on the caller driving
+// RunBackgroundFsync.
+func NewWriter(dir, filename, fsync string) (*Writer, error) {
+	path := filepath.Join(dir, filename)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open AOF file: %v", err)
+	}
+	return &Writer{path: path, file: file, bw: bufio.NewWriter(file), fsync: fsync}, nil
+}
+
+// Append writes one already RESP-encoded command to the log. While a
+// rewrite is in flight (see BeginRewrite), the command is queued instead so
+// BGREWRITEAOF never loses a write that arrives mid-rewrite.
+func (w *Writer) Append(payload []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.rewriting {
+		w.queue = append(w.queue, payload)
+		return nil
+	}
+
+	if _, err := w.bw.Write(payload); err != nil {
+		return err
+	}
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+	if w.fsync == "always" {
+		return w.file.Sync()
+	}
+	return nil
+}
+
+// RunBackgroundFsync fsyncs the log once per interval, forever. It backs the
+// "everysec" appendfsync policy and is meant to run in its own goroutine.
+func (w *Writer) RunBackgroundFsync(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		_ = w.Fsync()
+	}
+}
+
+// Fsync flushes the log to disk immediately.
+func (w *Writer) Fsync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Sync()
+}
+
+// BeginRewrite marks the writer mid-rewrite: further Append calls are
+// queued rather than applied to the file that is about to be replaced.
+func (w *Writer) BeginRewrite() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rewriting = true
+}
+
+// FinishRewrite reopens the writer against the freshly rewritten path and
+// flushes every command queued while the rewrite was running.
+func (w *Writer) FinishRewrite(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w.path = path
+	w.file = file
+	w.bw = bufio.NewWriter(file)
+
+	for _, payload := range w.queue {
+		if _, err := w.bw.Write(payload); err != nil {
+			return err
+		}
+	}
+	w.queue = nil
+	w.rewriting = false
+	return w.bw.Flush()
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/madmecodes/go_codeCrafters/redis-server/pattern"
+)
+
+// DiskStore is the Storage implementation backed by an embedded LevelDB
+// database instead of a Go map, so a dataset larger than RAM still fits.
+// Each value is stored as a small header (type byte + expiry millis)
+// followed by its encoded payload, so Get can expire a key lazily without a
+// separate index, the same way MemStore does against its in-memory item.
+type DiskStore struct {
+	db *leveldb.DB
+
+	scanCursors sync.Map
+	cursorSeq   int64
+}
+
+// NewDiskStore opens (creating if necessary) the LevelDB database at path.
+func NewDiskStore(path string) (*DiskStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk store at %s: %v", path, err)
+	}
+	return &DiskStore{db: db}, nil
+}
+
+// encodeEntry prefixes payload with a type byte and an 8-byte expiry
+// timestamp (milliseconds since the epoch, 0 meaning "no expiry").
+func encodeEntry(valueType byte, payload []byte, px int64) []byte {
+	var expiresAtMs uint64
+	if px > 0 {
+		expiresAtMs = uint64(time.Now().Add(time.Duration(px) * time.Millisecond).UnixMilli())
+	}
+	entry := make([]byte, 9+len(payload))
+	entry[0] = valueType
+	binary.BigEndian.PutUint64(entry[1:9], expiresAtMs)
+	copy(entry[9:], payload)
+	return entry
+}
+
+// decodeEntry splits a stored entry back into its type, expiry, and payload.
+func decodeEntry(entry []byte) (valueType byte, hasExpiry bool, expiresAt time.Time, payload []byte) {
+	valueType = entry[0]
+	expiresAtMs := binary.BigEndian.Uint64(entry[1:9])
+	payload = entry[9:]
+	if expiresAtMs != 0 {
+		hasExpiry = true
+		expiresAt = time.UnixMilli(int64(expiresAtMs))
+	}
+	return
+}
+
+// get fetches and decodes the raw entry for key, deleting it and reporting a
+// miss if it has already expired.
+func (d *DiskStore) get(key string) (valueType byte, payload []byte, ok bool) {
+	raw, err := d.db.Get([]byte(key), nil)
+	if err != nil {
+		return 0, nil, false
+	}
+	valueType, hasExpiry, expiresAt, payload := decodeEntry(raw)
+	if hasExpiry && time.Now().After(expiresAt) {
+		d.db.Delete([]byte(key), nil)
+		return 0, nil, false
+	}
+	return valueType, payload, true
+}
+
+func (d *DiskStore) put(key string, valueType byte, payload []byte, px int64) {
+	d.db.Put([]byte(key), encodeEntry(valueType, payload, px), nil)
+}
+
+func (d *DiskStore) Get(key string) (string, bool) {
+	valueType, payload, ok := d.get(key)
+	if !ok || valueType != TypeString {
+		return "", false
+	}
+	return string(payload), true
+}
+
+func (d *DiskStore) Set(key, value string, px int64) {
+	d.put(key, TypeString, []byte(value), px)
+}
+
+func (d *DiskStore) SetList(key string, values []string, px int64) {
+	payload, _ := json.Marshal(values)
+	d.put(key, TypeList, payload, px)
+}
+
+func (d *DiskStore) SetHash(key string, fields map[string]string, px int64) {
+	payload, _ := json.Marshal(fields)
+	d.put(key, TypeHash, payload, px)
+}
+
+func (d *DiskStore) SetSet(key string, members []string, px int64) {
+	payload, _ := json.Marshal(members)
+	d.put(key, TypeSet, payload, px)
+}
+
+func (d *DiskStore) SetZSet(key string, members []ZSetMember, px int64) {
+	payload, _ := json.Marshal(members)
+	d.put(key, TypeZSet, payload, px)
+}
+
+// GetList, GetHash, GetSet, and GetZSet decode a key's current collection so
+// RPUSH/HSET/SADD/ZADD can read-modify-write it through SetList/SetHash/
+// SetSet/SetZSet, the same way Get backs SET.
+func (d *DiskStore) GetList(key string) ([]string, bool) {
+	valueType, payload, ok := d.get(key)
+	if !ok || valueType != TypeList {
+		return nil, false
+	}
+	var values []string
+	json.Unmarshal(payload, &values)
+	return values, true
+}
+
+func (d *DiskStore) GetHash(key string) (map[string]string, bool) {
+	valueType, payload, ok := d.get(key)
+	if !ok || valueType != TypeHash {
+		return nil, false
+	}
+	var fields map[string]string
+	json.Unmarshal(payload, &fields)
+	return fields, true
+}
+
+func (d *DiskStore) GetSet(key string) ([]string, bool) {
+	valueType, payload, ok := d.get(key)
+	if !ok || valueType != TypeSet {
+		return nil, false
+	}
+	var members []string
+	json.Unmarshal(payload, &members)
+	return members, true
+}
+
+func (d *DiskStore) GetZSet(key string) ([]ZSetMember, bool) {
+	valueType, payload, ok := d.get(key)
+	if !ok || valueType != TypeZSet {
+		return nil, false
+	}
+	var members []ZSetMember
+	json.Unmarshal(payload, &members)
+	return members, true
+}
+
+func (d *DiskStore) Delete(key string) bool {
+	exists, err := d.db.Has([]byte(key), nil)
+	if err != nil || !exists {
+		return false
+	}
+	d.db.Delete([]byte(key), nil)
+	return true
+}
+
+// Keys returns every live key matching the glob pattern. It walks the
+// database's own iterator rather than requiring the whole keyspace resident
+// in a Go map first, but — like real Redis's KEYS — it still has to
+// materialize every match into the returned slice before replying, since the
+// command isn't paginated; use SCAN for a dataset too large to return in one
+// reply.
+func (d *DiskStore) Keys(patternStr string) []string {
+	iter := d.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	now := time.Now()
+	keys := make([]string, 0)
+	for iter.Next() {
+		_, hasExpiry, expiresAt, _ := decodeEntry(iter.Value())
+		if hasExpiry && now.After(expiresAt) {
+			continue
+		}
+		key := string(iter.Key())
+		if pattern.Match(patternStr, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// allKeys snapshots every live key in iterator order, for Scan to page
+// through the same way MemStore pages through its map snapshot. The
+// snapshot itself is still a full in-memory key list — only the read off
+// LevelDB is incremental, not the cursor's working set — since a stable
+// SCAN cursor needs a fixed snapshot to resume from across calls.
+func (d *DiskStore) allKeys() []string {
+	iter := d.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	now := time.Now()
+	keys := make([]string, 0)
+	for iter.Next() {
+		_, hasExpiry, expiresAt, _ := decodeEntry(iter.Value())
+		if hasExpiry && now.After(expiresAt) {
+			continue
+		}
+		keys = append(keys, string(iter.Key()))
+	}
+	return keys
+}
+
+// Scan implements SCAN cursor [MATCH pattern] [COUNT n] [TYPE t] against the
+// LevelDB-backed keyspace.
+func (d *DiskStore) Scan(cursor int64, match string, count int, typeFilter string) (int64, []string) {
+	return paginateCursor(&d.scanCursors, &d.cursorSeq, cursor, func() []string {
+		keys := d.allKeys()
+		if typeFilter == "" {
+			return keys
+		}
+		filtered := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if d.Type(key) == typeFilter {
+				filtered = append(filtered, key)
+			}
+		}
+		return filtered
+	}, match, count)
+}
+
+func (d *DiskStore) Expire(key string, px int64) bool {
+	valueType, payload, ok := d.get(key)
+	if !ok {
+		return false
+	}
+	d.put(key, valueType, payload, px)
+	return true
+}
+
+func (d *DiskStore) TTL(key string) (int64, bool) {
+	raw, err := d.db.Get([]byte(key), nil)
+	if err != nil {
+		return 0, false
+	}
+	_, hasExpiry, expiresAt, _ := decodeEntry(raw)
+	if !hasExpiry {
+		return -1, true
+	}
+	return time.Until(expiresAt).Milliseconds(), true
+}
+
+func (d *DiskStore) Type(key string) string {
+	valueType, _, ok := d.get(key)
+	if !ok {
+		return ""
+	}
+	return typeName(valueType)
+}
+
+// Dump decodes every live entry back into the shape Writer.Write expects, so
+// SAVE/BGSAVE and BGREWRITEAOF can persist a disk-backed store the same way
+// they persist a MemStore. Like allKeys, reading off LevelDB is incremental
+// but the returned []KeyValue is not: Writer.Write takes the whole snapshot
+// at once, so a multi-GB dataset still needs a multi-GB Dump() here.
+func (d *DiskStore) Dump() []KeyValue {
+	iter := d.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	now := time.Now()
+	var pairs []KeyValue
+	for iter.Next() {
+		valueType, hasExpiry, expiresAt, payload := decodeEntry(iter.Value())
+		if hasExpiry && now.After(expiresAt) {
+			continue
+		}
+		pair := KeyValue{
+			Key:       string(iter.Key()),
+			Type:      valueType,
+			HasExpiry: hasExpiry,
+			ExpiresAt: expiresAt,
+		}
+		switch valueType {
+		case TypeString:
+			pair.Str = string(payload)
+		case TypeList:
+			json.Unmarshal(payload, &pair.List)
+		case TypeHash:
+			json.Unmarshal(payload, &pair.Hash)
+		case TypeSet:
+			json.Unmarshal(payload, &pair.Set)
+		case TypeZSet:
+			json.Unmarshal(payload, &pair.ZSet)
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
@@ -0,0 +1,106 @@
+// Package pattern implements the Redis glob-style matcher used by KEYS and
+// the MATCH option of SCAN/HSCAN/SSCAN/ZSCAN.
+package pattern
+
+// Match reports whether key matches the glob pattern: '*' matches any
+// sequence of bytes, '?' matches any single byte, '[abc]'/'[^abc]' match a
+// character class (with 'a-z' style ranges), and '\' escapes the following
+// byte to match it literally. It operates on raw bytes, so it is correct on
+// binary keys as well as UTF-8 text.
+func Match(pattern, key string) bool {
+	return match([]byte(pattern), []byte(key))
+}
+
+func match(pattern, key []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if match(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			end, matched := matchClass(pattern, key[0])
+			if !matched {
+				return false
+			}
+			pattern, key = pattern[end:], key[1:]
+
+		case '\\':
+			if len(pattern) < 2 || len(key) == 0 || key[0] != pattern[1] {
+				return false
+			}
+			pattern, key = pattern[2:], key[1:]
+
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass parses the "[...]" class starting at pattern[0] and reports
+// whether b matches it, along with the index just past the closing ']'.
+func matchClass(pattern []byte, b byte) (end int, matched bool) {
+	i := 1
+	negate := false
+	if i < len(pattern) && pattern[i] == '^' {
+		negate = true
+		i++
+	}
+
+	found := false
+	for i < len(pattern) && pattern[i] != ']' {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			if pattern[i+1] == b {
+				found = true
+			}
+			i += 2
+
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if b >= lo && b <= hi {
+				found = true
+			}
+			i += 3
+
+		default:
+			if pattern[i] == b {
+				found = true
+			}
+			i++
+		}
+	}
+	if i < len(pattern) {
+		i++ // consume the closing ']'
+	}
+	if negate {
+		found = !found
+	}
+	return i, found
+}
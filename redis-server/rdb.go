@@ -1,28 +1,58 @@
 package main
 
 import (
+	"bufio"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 )
 
 const (
 	RDBHeader     = "REDIS0011"
 	TypeString    = 0
+	TypeList      = 1
+	TypeSet       = 2
+	TypeZSet      = 3
+	TypeHash      = 4
+	TypeZSetZiplist   = 12
+	TypeHashZiplist   = 13
+	TypeListQuicklist = 14
+	TypeHashListpack    = 16
+	TypeZSetListpack    = 17
+	TypeListQuicklist2  = 18
 	MetadataStart = 0xFA
 	DatabaseStart = 0xFE
 	ExpireTimeMs  = 0xFC
 	ExpireTime    = 0xFD
 	EOF           = 0xFF
 	ResizeDB      = 0xFB
+
+	// String-encoding markers carried in the low 6 bits of a "11" length byte.
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
 )
 
+// ZSetMember is one member/score pair of a sorted set loaded from an RDB file.
+type ZSetMember struct {
+	Member string
+	Score  float64
+}
+
 type KeyValue struct {
 	Key       string
-	Value     string
+	Type      byte
+	Str       string
+	List      []string
+	Hash      map[string]string
+	Set       []string
+	ZSet      []ZSetMember
 	HasExpiry bool
 	ExpiresAt time.Time
 }
@@ -37,64 +67,78 @@ func NewReader(dir, filename string) *Reader {
 	}
 }
 
+// Read returns every key-value pair in the file, in the order they appear.
+// It returns (nil, nil) if the file does not exist yet.
 func (r *Reader) Read() ([]KeyValue, error) {
+	var pairs []KeyValue
+	err := r.ReadInto(func(pair KeyValue) error {
+		pairs = append(pairs, pair)
+		return nil
+	})
+	return pairs, err
+}
+
+// ReadInto parses the file and calls load for each key-value pair as soon as
+// it is decoded, rather than accumulating the whole keyspace in a slice
+// first. This is what lets a disk-backed store load a dump far larger than
+// RAM: only one pair is ever live at a time.
+func (r *Reader) ReadInto(load func(KeyValue) error) error {
 	file, err := os.Open(r.filepath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil
+			return nil
 		}
-		return nil, fmt.Errorf("failed to open RDB file: %v", err)
+		return fmt.Errorf("failed to open RDB file: %v", err)
 	}
 	defer file.Close()
 
 	// Read and validate the header
 	header := make([]byte, len(RDBHeader))
 	if _, err := io.ReadFull(file, header); err != nil {
-		return nil, fmt.Errorf("failed to read header: %v", err)
+		return fmt.Errorf("failed to read header: %v", err)
 	}
 	if string(header) != RDBHeader {
-		return nil, fmt.Errorf("invalid RDB header")
+		return fmt.Errorf("invalid RDB header")
 	}
 
-	var pairs []KeyValue
 	opcode := make([]byte, 1)
 
 	for {
 		if _, err := file.Read(opcode); err != nil {
 			if err == io.EOF {
-				return pairs, nil
+				return nil
 			}
-			return nil, fmt.Errorf("failed to read opcode: %v", err)
+			return fmt.Errorf("failed to read opcode: %v", err)
 		}
 
 		switch opcode[0] {
 		case MetadataStart:
 			// Skip metadata
 			if err := r.skipMetadata(file); err != nil {
-				return nil, fmt.Errorf("failed to skip metadata: %v", err)
+				return fmt.Errorf("failed to skip metadata: %v", err)
 			}
 
 		case DatabaseStart:
 			// Read database number
 			if _, err := r.readLength(file); err != nil {
-				return nil, fmt.Errorf("failed to read database number: %v", err)
+				return fmt.Errorf("failed to read database number: %v", err)
 			}
 
 			// Check for ResizeDB
 			if _, err := file.Read(opcode); err != nil {
-				return nil, fmt.Errorf("failed to read after database number: %v", err)
+				return fmt.Errorf("failed to read after database number: %v", err)
 			}
 
 			if opcode[0] == ResizeDB {
 				// Skip hash table sizes
 				if _, err := r.readLength(file); err != nil {
-					return nil, err
+					return err
 				}
 				if _, err := r.readLength(file); err != nil {
-					return nil, err
+					return err
 				}
 				if _, err := file.Read(opcode); err != nil {
-					return nil, err
+					return err
 				}
 			}
 
@@ -107,58 +151,265 @@ func (r *Reader) Read() ([]KeyValue, error) {
 				case ExpireTime:
 					var expires uint32
 					if err := binary.Read(file, binary.LittleEndian, &expires); err != nil {
-						return nil, err
+						return err
 					}
 					pair.ExpiresAt = time.Unix(int64(expires), 0)
 					pair.HasExpiry = true
 					if _, err := file.Read(opcode); err != nil {
-						return nil, err
+						return err
 					}
 				case ExpireTimeMs:
 					var expires uint64
 					if err := binary.Read(file, binary.LittleEndian, &expires); err != nil {
-						return nil, err
+						return err
 					}
 					pair.ExpiresAt = time.UnixMilli(int64(expires))
 					pair.HasExpiry = true
 					if _, err := file.Read(opcode); err != nil {
-						return nil, err
+						return err
 					}
 				}
 
-				if opcode[0] != TypeString {
-					return nil, fmt.Errorf("unsupported value type: %d", opcode[0])
-				}
+				pair.Type = opcode[0]
 
-				// Read key and value strings
 				key, err := r.readString(file)
 				if err != nil {
-					return nil, fmt.Errorf("failed to read key: %v", err)
+					return fmt.Errorf("failed to read key: %v", err)
 				}
 				pair.Key = key
 
-				value, err := r.readString(file)
-				if err != nil {
-					return nil, fmt.Errorf("failed to read value: %v", err)
+				if err := r.readValue(file, &pair); err != nil {
+					return fmt.Errorf("failed to read value for key %q: %v", key, err)
 				}
-				pair.Value = value
 
-				// Add the parsed pair to the list
-				pairs = append(pairs, pair)
+				if err := load(pair); err != nil {
+					return err
+				}
 
 				// Read the next opcode
 				if _, err := file.Read(opcode); err != nil {
-					return nil, fmt.Errorf("failed to read next opcode: %v", err)
+					return fmt.Errorf("failed to read next opcode: %v", err)
 				}
 			}
 
+			// The inner loop already consumed the EOF opcode to exit; treat
+			// it as terminal here too instead of falling back to the outer
+			// loop and reading another byte, which would read into the
+			// trailing CRC64 checksum Writer.Write always appends.
+			if opcode[0] == EOF {
+				return nil
+			}
+
 		case EOF:
-			return pairs, nil
+			return nil
 
 		default:
-			return nil, fmt.Errorf("unsupported opcode: %d", opcode[0])
+			return fmt.Errorf("unsupported opcode: %d", opcode[0])
+		}
+	}
+}
+
+// readValue dispatches on pair.Type and fills in the matching field of pair.
+func (r *Reader) readValue(file *os.File, pair *KeyValue) error {
+	switch pair.Type {
+	case TypeString:
+		value, err := r.readString(file)
+		if err != nil {
+			return err
+		}
+		pair.Str = value
+
+	case TypeList, TypeSet:
+		count, err := r.readLength(file)
+		if err != nil {
+			return err
+		}
+		items := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			item, err := r.readString(file)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		if pair.Type == TypeList {
+			pair.List = items
+		} else {
+			pair.Set = items
+		}
+
+	case TypeHash:
+		count, err := r.readLength(file)
+		if err != nil {
+			return err
+		}
+		hash := make(map[string]string, count)
+		for i := 0; i < count; i++ {
+			field, err := r.readString(file)
+			if err != nil {
+				return err
+			}
+			value, err := r.readString(file)
+			if err != nil {
+				return err
+			}
+			hash[field] = value
+		}
+		pair.Hash = hash
+
+	case TypeZSet:
+		count, err := r.readLength(file)
+		if err != nil {
+			return err
+		}
+		members := make([]ZSetMember, 0, count)
+		for i := 0; i < count; i++ {
+			member, err := r.readString(file)
+			if err != nil {
+				return err
+			}
+			score, err := r.readZSetScore(file)
+			if err != nil {
+				return err
+			}
+			members = append(members, ZSetMember{Member: member, Score: score})
+		}
+		pair.ZSet = members
+
+	case TypeHashZiplist:
+		entries, err := r.readZiplistBlob(file)
+		if err != nil {
+			return err
+		}
+		pair.Hash = entriesToHash(entries)
+
+	case TypeZSetZiplist:
+		entries, err := r.readZiplistBlob(file)
+		if err != nil {
+			return err
+		}
+		pair.ZSet = entriesToZSet(entries)
+
+	case TypeListQuicklist:
+		nodeCount, err := r.readLength(file)
+		if err != nil {
+			return err
+		}
+		var items []string
+		for i := 0; i < nodeCount; i++ {
+			entries, err := r.readZiplistBlob(file)
+			if err != nil {
+				return err
+			}
+			items = append(items, entries...)
+		}
+		pair.List = items
+
+	case TypeHashListpack:
+		entries, err := r.readListpackBlob(file)
+		if err != nil {
+			return err
+		}
+		pair.Hash = entriesToHash(entries)
+
+	case TypeZSetListpack:
+		entries, err := r.readListpackBlob(file)
+		if err != nil {
+			return err
+		}
+		pair.ZSet = entriesToZSet(entries)
+
+	case TypeListQuicklist2:
+		nodeCount, err := r.readLength(file)
+		if err != nil {
+			return err
+		}
+		var items []string
+		for i := 0; i < nodeCount; i++ {
+			// Each node is container-type prefixed (1 = plain, 2 = packed/listpack).
+			// A plain node is a bare length-prefixed string holding a single
+			// oversized element, not a listpack blob, so it must not be run
+			// through decodeListpack.
+			container, err := r.readLength(file)
+			if err != nil {
+				return err
+			}
+			if container == 1 {
+				item, err := r.readString(file)
+				if err != nil {
+					return err
+				}
+				items = append(items, item)
+				continue
+			}
+			entries, err := r.readListpackBlob(file)
+			if err != nil {
+				return err
+			}
+			items = append(items, entries...)
+		}
+		pair.List = items
+
+	default:
+		return fmt.Errorf("unsupported value type: %d", pair.Type)
+	}
+	return nil
+}
+
+func entriesToHash(entries []string) map[string]string {
+	hash := make(map[string]string, len(entries)/2)
+	for i := 0; i+1 < len(entries); i += 2 {
+		hash[entries[i]] = entries[i+1]
+	}
+	return hash
+}
+
+func entriesToZSet(entries []string) []ZSetMember {
+	members := make([]ZSetMember, 0, len(entries)/2)
+	for i := 0; i+1 < len(entries); i += 2 {
+		score, _ := strconv.ParseFloat(entries[i+1], 64)
+		members = append(members, ZSetMember{Member: entries[i], Score: score})
+	}
+	return members
+}
+
+func (r *Reader) readZSetScore(file *os.File) (float64, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(file, b); err != nil {
+		return 0, err
+	}
+	switch b[0] {
+	case 255:
+		return math.Inf(-1), nil
+	case 254:
+		return math.Inf(1), nil
+	case 253:
+		return math.NaN(), nil
+	default:
+		data := make([]byte, b[0])
+		if _, err := io.ReadFull(file, data); err != nil {
+			return 0, err
 		}
+		return strconv.ParseFloat(string(data), 64)
+	}
+}
+
+// readZiplistBlob reads a length-prefixed ziplist string and decodes its entries.
+func (r *Reader) readZiplistBlob(file *os.File) ([]string, error) {
+	blob, err := r.readString(file)
+	if err != nil {
+		return nil, err
+	}
+	return decodeZiplist([]byte(blob))
+}
+
+// readListpackBlob reads a length-prefixed listpack string and decodes its entries.
+func (r *Reader) readListpackBlob(file *os.File) ([]string, error) {
+	blob, err := r.readString(file)
+	if err != nil {
+		return nil, err
 	}
+	return decodeListpack([]byte(blob))
 }
 
 func (r *Reader) skipMetadata(file *os.File) error {
@@ -174,33 +425,475 @@ func (r *Reader) skipMetadata(file *os.File) error {
 }
 
 func (r *Reader) skipString(file *os.File) error {
-	length, err := r.readLength(file)
-	if err != nil {
+	if _, err := r.readString(file); err != nil {
 		return err
 	}
-	_, err = file.Seek(int64(length), io.SeekCurrent)
-	return err
+	return nil
 }
 
+// readString reads a length-encoded string, transparently handling the
+// integer and LZF-compressed special encodings carried by a "11" length byte.
 func (r *Reader) readString(file *os.File) (string, error) {
-	length, err := r.readLength(file)
+	length, encoded, encType, err := r.readLengthOrEncoding(file)
 	if err != nil {
 		return "", err
 	}
 
+	if encoded {
+		switch encType {
+		case encInt8:
+			var v int8
+			if err := binary.Read(file, binary.LittleEndian, &v); err != nil {
+				return "", err
+			}
+			return strconv.Itoa(int(v)), nil
+		case encInt16:
+			var v int16
+			if err := binary.Read(file, binary.LittleEndian, &v); err != nil {
+				return "", err
+			}
+			return strconv.Itoa(int(v)), nil
+		case encInt32:
+			var v int32
+			if err := binary.Read(file, binary.LittleEndian, &v); err != nil {
+				return "", err
+			}
+			return strconv.Itoa(int(v)), nil
+		case encLZF:
+			compressedLen, err := r.readLength(file)
+			if err != nil {
+				return "", fmt.Errorf("failed to read LZF compressed length: %v", err)
+			}
+			uncompressedLen, err := r.readLength(file)
+			if err != nil {
+				return "", fmt.Errorf("failed to read LZF uncompressed length: %v", err)
+			}
+			compressed := make([]byte, compressedLen)
+			if _, err := io.ReadFull(file, compressed); err != nil {
+				return "", fmt.Errorf("failed to read LZF payload: %v", err)
+			}
+			return lzfDecompress(compressed, uncompressedLen)
+		default:
+			return "", fmt.Errorf("unsupported string encoding: %d", encType)
+		}
+	}
+
 	data := make([]byte, length)
-	_, err = io.ReadFull(file, data)
-	if err != nil {
+	if _, err := io.ReadFull(file, data); err != nil {
 		return "", fmt.Errorf("failed to read string data: %v", err)
 	}
-
 	return string(data), nil
 }
 
+// readLength reads a plain length-encoded integer. It is an error for the
+// length byte to carry a special string encoding in this context.
 func (r *Reader) readLength(file *os.File) (int, error) {
-	var length int32
-	if err := binary.Read(file, binary.LittleEndian, &length); err != nil {
-		return 0, fmt.Errorf("failed to read length: %v", err)
+	length, encoded, _, err := r.readLengthOrEncoding(file)
+	if err != nil {
+		return 0, err
+	}
+	if encoded {
+		return 0, fmt.Errorf("unexpected special encoding where a plain length was expected")
+	}
+	return length, nil
+}
+
+// readLengthOrEncoding implements the RDB length-prefix format: the top two
+// bits of the first byte select between a 6-bit length, a 14-bit length, a
+// 32/64-bit length, and a specially-encoded string (int8/16/32 or LZF).
+func (r *Reader) readLengthOrEncoding(file *os.File) (length int, encoded bool, encType byte, err error) {
+	b := make([]byte, 1)
+	if _, err = io.ReadFull(file, b); err != nil {
+		return 0, false, 0, fmt.Errorf("failed to read length byte: %v", err)
+	}
+
+	switch b[0] >> 6 {
+	case 0b00:
+		return int(b[0] & 0x3F), false, 0, nil
+
+	case 0b01:
+		b2 := make([]byte, 1)
+		if _, err = io.ReadFull(file, b2); err != nil {
+			return 0, false, 0, err
+		}
+		return int(b[0]&0x3F)<<8 | int(b2[0]), false, 0, nil
+
+	case 0b10:
+		if b[0] == 0x81 {
+			var v uint64
+			if err = binary.Read(file, binary.BigEndian, &v); err != nil {
+				return 0, false, 0, err
+			}
+			return int(v), false, 0, nil
+		}
+		var v uint32
+		if err = binary.Read(file, binary.BigEndian, &v); err != nil {
+			return 0, false, 0, err
+		}
+		return int(v), false, 0, nil
+
+	default: // 0b11
+		return 0, true, b[0] & 0x3F, nil
+	}
+}
+
+// lzfDecompress implements the LZF back-reference/literal-run decompression
+// used to store compressed strings inside RDB files.
+func lzfDecompress(data []byte, expectedLen int) (string, error) {
+	out := make([]byte, 0, expectedLen)
+	i := 0
+	for i < len(data) {
+		ctrl := int(data[i])
+		i++
+
+		if ctrl < 32 {
+			length := ctrl + 1
+			if i+length > len(data) {
+				return "", fmt.Errorf("lzf: literal run exceeds input")
+			}
+			out = append(out, data[i:i+length]...)
+			i += length
+			continue
+		}
+
+		length := ctrl >> 5
+		if length == 7 {
+			if i >= len(data) {
+				return "", fmt.Errorf("lzf: truncated length extension")
+			}
+			length += int(data[i])
+			i++
+		}
+		if i >= len(data) {
+			return "", fmt.Errorf("lzf: truncated back-reference")
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(data[i]) - 1
+		i++
+		if ref < 0 {
+			return "", fmt.Errorf("lzf: invalid back-reference")
+		}
+		for j := 0; j <= length+1; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+	if len(out) != expectedLen {
+		return "", fmt.Errorf("lzf: decompressed length mismatch: got %d want %d", len(out), expectedLen)
+	}
+	return string(out), nil
+}
+
+// decodeZiplist parses a legacy ziplist blob (used by hash/zset ziplist
+// encodings and quicklist nodes) into its flat list of string entries.
+func decodeZiplist(blob []byte) ([]string, error) {
+	if len(blob) < 11 {
+		return nil, fmt.Errorf("ziplist: blob too short")
+	}
+	// 4 bytes zlbytes, 4 bytes zltail, 2 bytes zllen, then entries, then 0xFF.
+	pos := 10
+	var entries []string
+
+	for pos < len(blob) && blob[pos] != 0xFF {
+		// prevlen: 1 byte, or 0xFE followed by a 4-byte length.
+		if blob[pos] == 0xFE {
+			pos += 5
+		} else {
+			pos++
+		}
+		if pos >= len(blob) {
+			return nil, fmt.Errorf("ziplist: truncated entry header")
+		}
+
+		value, next, err := decodeZiplistEntry(blob, pos)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, value)
+		pos = next
+	}
+	return entries, nil
+}
+
+func decodeZiplistEntry(blob []byte, pos int) (string, int, error) {
+	enc := blob[pos]
+	switch enc >> 6 {
+	case 0b00:
+		length := int(enc & 0x3F)
+		start := pos + 1
+		return string(blob[start : start+length]), start + length, nil
+	case 0b01:
+		length := int(enc&0x3F)<<8 | int(blob[pos+1])
+		start := pos + 2
+		return string(blob[start : start+length]), start + length, nil
+	case 0b10:
+		length := int(binary.BigEndian.Uint32(blob[pos+1 : pos+5]))
+		start := pos + 5
+		return string(blob[start : start+length]), start + length, nil
+	}
+
+	// 0b11: integer encodings.
+	switch enc {
+	case 0xC0:
+		v := int16(binary.LittleEndian.Uint16(blob[pos+1 : pos+3]))
+		return strconv.Itoa(int(v)), pos + 3, nil
+	case 0xD0:
+		v := int32(binary.LittleEndian.Uint32(blob[pos+1 : pos+5]))
+		return strconv.Itoa(int(v)), pos + 5, nil
+	case 0xE0:
+		v := int64(binary.LittleEndian.Uint64(blob[pos+1 : pos+9]))
+		return strconv.FormatInt(v, 10), pos + 9, nil
+	case 0xF0:
+		b := blob[pos+1 : pos+4]
+		v := int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
+		if v&0x800000 != 0 {
+			v |= -1 << 24 // sign-extend 24-bit value
+		}
+		return strconv.Itoa(int(v)), pos + 4, nil
+	case 0xFE:
+		v := int8(blob[pos+1])
+		return strconv.Itoa(int(v)), pos + 2, nil
+	}
+
+	if enc >= 0xF1 && enc <= 0xFD {
+		return strconv.Itoa(int(enc&0x0F) - 1), pos + 1, nil
+	}
+
+	return "", 0, fmt.Errorf("ziplist: unsupported entry encoding %#x", enc)
+}
+
+// decodeListpack parses a listpack blob into its flat list of string entries.
+func decodeListpack(blob []byte) ([]string, error) {
+	if len(blob) < 7 {
+		return nil, fmt.Errorf("listpack: blob too short")
+	}
+	// 4 bytes total-bytes, 2 bytes num-elements, then entries, then 0xFF.
+	pos := 6
+	var entries []string
+
+	for pos < len(blob) && blob[pos] != 0xFF {
+		value, entryLen, err := decodeListpackEntry(blob, pos)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, value)
+		pos += entryLen + backlenSize(entryLen)
+	}
+	return entries, nil
+}
+
+// backlenSize returns the number of bytes used to encode entryLen as a
+// listpack "backlen" (a little-endian base-128 varint).
+func backlenSize(entryLen int) int {
+	switch {
+	case entryLen <= 127:
+		return 1
+	case entryLen < 16384:
+		return 2
+	case entryLen < 2097152:
+		return 3
+	case entryLen < 268435456:
+		return 4
+	default:
+		return 5
+	}
+}
+
+func decodeListpackEntry(blob []byte, pos int) (string, int, error) {
+	b := blob[pos]
+	switch {
+	case b&0x80 == 0: // 0xxxxxxx: 7-bit uint
+		return strconv.Itoa(int(b)), 1, nil
+
+	case b&0xC0 == 0x80: // 10xxxxxx: 6-bit length string
+		length := int(b & 0x3F)
+		return string(blob[pos+1 : pos+1+length]), 1 + length, nil
+
+	case b&0xE0 == 0xC0: // 110xxxxx yyyyyyyy: 13-bit signed int
+		raw := int16(b&0x1F)<<8 | int16(blob[pos+1])
+		if raw&0x1000 != 0 {
+			raw |= -1 << 13
+		}
+		return strconv.Itoa(int(raw)), 2, nil
+
+	case b&0xF0 == 0xE0: // 1110xxxx + 1 byte: 12-bit length string
+		length := int(b&0x0F)<<8 | int(blob[pos+1])
+		start := pos + 2
+		return string(blob[start : start+length]), 2 + length, nil
+
+	case b == 0xF1: // int16
+		v := int16(binary.LittleEndian.Uint16(blob[pos+1 : pos+3]))
+		return strconv.Itoa(int(v)), 3, nil
+
+	case b == 0xF2: // int24
+		raw := int32(blob[pos+1]) | int32(blob[pos+2])<<8 | int32(blob[pos+3])<<16
+		if raw&0x800000 != 0 {
+			raw |= -1 << 24
+		}
+		return strconv.Itoa(int(raw)), 4, nil
+
+	case b == 0xF3: // int32
+		v := int32(binary.LittleEndian.Uint32(blob[pos+1 : pos+5]))
+		return strconv.Itoa(int(v)), 5, nil
+
+	case b == 0xF4: // int64
+		v := int64(binary.LittleEndian.Uint64(blob[pos+1 : pos+9]))
+		return strconv.FormatInt(v, 10), 9, nil
+
+	case b == 0xF0: // 32-bit length string
+		length := int(binary.LittleEndian.Uint32(blob[pos+1 : pos+5]))
+		start := pos + 5
+		return string(blob[start : start+length]), 5 + length, nil
+
+	default:
+		return "", 0, fmt.Errorf("listpack: unsupported entry encoding %#x", b)
+	}
+}
+
+// Writer emits a valid RDB file so the in-memory Store can be persisted
+// back to disk by SAVE/BGSAVE.
+type Writer struct {
+	filepath string
+}
+
+func NewWriter(dir, filename string) *Writer {
+	return &Writer{
+		filepath: filepath.Join(dir, filename),
+	}
+}
+
+// Write serializes pairs to the writer's target path as a complete RDB file.
+func (w *Writer) Write(pairs []KeyValue) error {
+	file, err := os.Create(w.filepath)
+	if err != nil {
+		return fmt.Errorf("failed to create RDB file: %v", err)
+	}
+	defer file.Close()
+
+	bw := bufio.NewWriter(file)
+
+	if _, err := bw.WriteString(RDBHeader); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(DatabaseStart); err != nil {
+		return err
+	}
+	if err := writeLength(bw, 0); err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		if pair.HasExpiry {
+			if err := bw.WriteByte(ExpireTimeMs); err != nil {
+				return err
+			}
+			if err := binary.Write(bw, binary.LittleEndian, uint64(pair.ExpiresAt.UnixMilli())); err != nil {
+				return err
+			}
+		}
+
+		if err := bw.WriteByte(pair.Type); err != nil {
+			return err
+		}
+		if err := writeString(bw, pair.Key); err != nil {
+			return err
+		}
+		if err := writeValue(bw, pair); err != nil {
+			return err
+		}
+	}
+
+	if err := bw.WriteByte(EOF); err != nil {
+		return err
+	}
+	// 8-byte CRC64 checksum; 0 means "checksum disabled" which real Redis accepts.
+	if err := binary.Write(bw, binary.LittleEndian, uint64(0)); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func writeValue(bw *bufio.Writer, pair KeyValue) error {
+	switch pair.Type {
+	case TypeString:
+		return writeString(bw, pair.Str)
+
+	case TypeList:
+		if err := writeLength(bw, len(pair.List)); err != nil {
+			return err
+		}
+		for _, item := range pair.List {
+			if err := writeString(bw, item); err != nil {
+				return err
+			}
+		}
+
+	case TypeSet:
+		if err := writeLength(bw, len(pair.Set)); err != nil {
+			return err
+		}
+		for _, item := range pair.Set {
+			if err := writeString(bw, item); err != nil {
+				return err
+			}
+		}
+
+	case TypeHash:
+		if err := writeLength(bw, len(pair.Hash)); err != nil {
+			return err
+		}
+		for field, value := range pair.Hash {
+			if err := writeString(bw, field); err != nil {
+				return err
+			}
+			if err := writeString(bw, value); err != nil {
+				return err
+			}
+		}
+
+	case TypeZSet:
+		if err := writeLength(bw, len(pair.ZSet)); err != nil {
+			return err
+		}
+		for _, member := range pair.ZSet {
+			if err := writeString(bw, member.Member); err != nil {
+				return err
+			}
+			scoreStr := strconv.FormatFloat(member.Score, 'g', -1, 64)
+			if err := bw.WriteByte(byte(len(scoreStr))); err != nil {
+				return err
+			}
+			if _, err := bw.WriteString(scoreStr); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported value type for write: %d", pair.Type)
+	}
+	return nil
+}
+
+func writeLength(bw *bufio.Writer, length int) error {
+	switch {
+	case length < 1<<6:
+		return bw.WriteByte(byte(length))
+	case length < 1<<14:
+		if err := bw.WriteByte(0x40 | byte(length>>8)); err != nil {
+			return err
+		}
+		return bw.WriteByte(byte(length))
+	default:
+		if err := bw.WriteByte(0x80); err != nil {
+			return err
+		}
+		return binary.Write(bw, binary.BigEndian, uint32(length))
 	}
-	return int(length), nil
+}
+
+func writeString(bw *bufio.Writer, s string) error {
+	if err := writeLength(bw, len(s)); err != nil {
+		return err
+	}
+	_, err := bw.WriteString(s)
+	return err
 }
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/madmecodes/go_codeCrafters/redis-server/resp"
+)
+
+// replicaEntry tracks one connected replica's socket and the replication
+// offset it has most recently acknowledged via REPLCONF ACK.
+type replicaEntry struct {
+	conn      net.Conn
+	writeMu   sync.Mutex
+	ackOffset int64
+}
+
+var replicationState = struct {
+	mu       sync.Mutex
+	replicas []*replicaEntry
+	replid   string
+	offset   int64
+}{}
+
+// initReplication assigns this server's replication ID. It must run before
+// the listener is opened since PSYNC/INFO both depend on it.
+func initReplication() {
+	replicationState.replid = randomReplID()
+}
+
+func randomReplID() string {
+	const charset = "0123456789abcdef"
+	id := make([]byte, 40)
+	for i := range id {
+		id[i] = charset[rand.Intn(len(charset))]
+	}
+	return string(id)
+}
+
+func isReplica() bool {
+	return config.replicaof != ""
+}
+
+func replicationInfo() string {
+	role := "master"
+	if isReplica() {
+		role = "slave"
+	}
+	return fmt.Sprintf(
+		"# Replication\r\nrole:%s\r\nmaster_replid:%s\r\nmaster_repl_offset:%d\r\n",
+		role, replicationState.replid, atomic.LoadInt64(&replicationState.offset),
+	)
+}
+
+// sendFullResync replies to a PSYNC with a +FULLRESYNC header followed by a
+// synthesized empty RDB snapshot (no keys), bulk-string framed but without a
+// trailing CRLF.
+func sendFullResync(conn net.Conn) error {
+	offset := atomic.LoadInt64(&replicationState.offset)
+	if _, err := conn.Write([]byte(fmt.Sprintf("+FULLRESYNC %s %d\r\n", replicationState.replid, offset))); err != nil {
+		return err
+	}
+
+	rdb := synthesizeEmptyRDB()
+	if _, err := conn.Write([]byte(fmt.Sprintf("$%d\r\n", len(rdb)))); err != nil {
+		return err
+	}
+	_, err := conn.Write(rdb)
+	return err
+}
+
+func synthesizeEmptyRDB() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(RDBHeader)
+	buf.WriteByte(EOF)
+	binary.Write(buf, binary.LittleEndian, uint64(0))
+	return buf.Bytes()
+}
+
+func registerReplica(conn net.Conn) {
+	entry := &replicaEntry{conn: conn}
+	replicationState.mu.Lock()
+	replicationState.replicas = append(replicationState.replicas, entry)
+	replicationState.mu.Unlock()
+}
+
+func updateReplicaAck(conn net.Conn, offset int64) {
+	replicationState.mu.Lock()
+	defer replicationState.mu.Unlock()
+	for _, rep := range replicationState.replicas {
+		if rep.conn == conn {
+			atomic.StoreInt64(&rep.ackOffset, offset)
+			return
+		}
+	}
+}
+
+// propagateToReplicas re-encodes a write command as a RESP array and fans it
+// out to every connected replica, advancing the master's replication offset
+// even if nobody is currently listening.
+func propagateToReplicas(elements []string) {
+	payload := encodeRESPArray(elements)
+	atomic.AddInt64(&replicationState.offset, int64(len(payload)))
+
+	replicationState.mu.Lock()
+	replicas := append([]*replicaEntry(nil), replicationState.replicas...)
+	replicationState.mu.Unlock()
+
+	for _, rep := range replicas {
+		rep.writeMu.Lock()
+		_, err := rep.conn.Write(payload)
+		rep.writeMu.Unlock()
+		if err != nil {
+			log.Printf("Error propagating to replica: %v", err)
+		}
+	}
+}
+
+// waitForReplicas blocks until numReplicas have acknowledged the current
+// replication offset, or timeoutMs elapses (0 means wait forever).
+func waitForReplicas(numReplicas, timeoutMs int) int {
+	target := atomic.LoadInt64(&replicationState.offset)
+
+	replicationState.mu.Lock()
+	replicas := append([]*replicaEntry(nil), replicationState.replicas...)
+	replicationState.mu.Unlock()
+
+	countAcked := func() int {
+		n := 0
+		for _, rep := range replicas {
+			if atomic.LoadInt64(&rep.ackOffset) >= target {
+				n++
+			}
+		}
+		return n
+	}
+
+	if len(replicas) == 0 || countAcked() >= numReplicas {
+		return countAcked()
+	}
+
+	propagateToReplicas([]string{"REPLCONF", "GETACK", "*"})
+
+	deadline := time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+	for timeoutMs <= 0 || time.Now().Before(deadline) {
+		if countAcked() >= numReplicas {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return countAcked()
+}
+
+// encodeRESPArray serializes elements as a RESP array of bulk strings, the
+// wire format every client command and propagated write uses.
+func encodeRESPArray(elements []string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(elements))
+	for _, el := range elements {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(el), el)
+	}
+	return buf.Bytes()
+}
+
+// startReplicaOf runs the replica side of the protocol: it performs the
+// handshake against the master, loads the RDB snapshot it sends back, and
+// then applies every propagated command to the local store forever.
+func startReplicaOf(masterAddr string) {
+	parts := strings.Fields(masterAddr)
+	if len(parts) != 2 {
+		log.Printf("Invalid --replicaof value %q, expected \"<host> <port>\"", masterAddr)
+		return
+	}
+	host, port := parts[0], parts[1]
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		log.Printf("Error connecting to master %s:%s: %v", host, port, err)
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	decoder := resp.NewDecoder(reader)
+
+	if err := sendHandshakeCommand(conn, decoder, []string{"PING"}); err != nil {
+		log.Printf("Replication handshake failed at PING: %v", err)
+		return
+	}
+	if err := sendHandshakeCommand(conn, decoder, []string{"REPLCONF", "listening-port", config.port}); err != nil {
+		log.Printf("Replication handshake failed at REPLCONF listening-port: %v", err)
+		return
+	}
+	if err := sendHandshakeCommand(conn, decoder, []string{"REPLCONF", "capa", "psync2"}); err != nil {
+		log.Printf("Replication handshake failed at REPLCONF capa: %v", err)
+		return
+	}
+	if _, err := conn.Write(encodeRESPArray([]string{"PSYNC", "?", "-1"})); err != nil {
+		log.Printf("Replication handshake failed sending PSYNC: %v", err)
+		return
+	}
+
+	fullResync, err := decoder.Decode()
+	if err != nil {
+		log.Printf("Replication handshake failed reading FULLRESYNC: %v", err)
+		return
+	}
+	fields := strings.Fields(fullResync.Str)
+	var offset int64
+	if len(fields) == 3 {
+		offset, _ = strconv.ParseInt(fields[2], 10, 64)
+	}
+
+	if err := loadRDBFromMaster(reader); err != nil {
+		log.Printf("Error loading RDB snapshot from master: %v", err)
+		return
+	}
+
+	replicateFromMaster(conn, decoder, offset)
+}
+
+// sendHandshakeCommand writes one handshake command and decodes (without
+// otherwise inspecting) its reply, so a malformed or missing response fails
+// the handshake instead of being silently accepted.
+func sendHandshakeCommand(conn net.Conn, decoder *resp.Decoder, elements []string) error {
+	if _, err := conn.Write(encodeRESPArray(elements)); err != nil {
+		return err
+	}
+	_, err := decoder.Decode()
+	return err
+}
+
+// loadRDBFromMaster reads the bulk-string-framed RDB payload ($<len>\r\n with
+// no trailing CRLF) that follows +FULLRESYNC and loads it into the store.
+func loadRDBFromMaster(reader *bufio.Reader) error {
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	header = strings.TrimSpace(header)
+	if !strings.HasPrefix(header, "$") {
+		return fmt.Errorf("expected bulk string RDB header, got %q", header)
+	}
+	length, err := strconv.Atoi(header[1:])
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp("", "replica-rdb-*.rdb")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	tmpFile.Close()
+
+	return (&Reader{filepath: tmpPath}).ReadInto(loadPairIntoStore)
+}
+
+// replicateFromMaster continuously reads propagated RESP commands from the
+// master, applies them to the local store, and tracks the replication
+// offset so REPLCONF GETACK can be answered accurately. The master always
+// propagates via encodeRESPArray, so re-encoding the decoded elements the
+// same way recovers the exact byte length consumed without the decoder
+// needing to expose one itself.
+func replicateFromMaster(conn net.Conn, decoder *resp.Decoder, offset int64) {
+	for {
+		value, err := decoder.Decode()
+		if err != nil {
+			log.Printf("Replication stream closed: %v", err)
+			return
+		}
+		elements := value.StringElements()
+		offset += int64(len(encodeRESPArray(elements)))
+
+		if len(elements) == 0 {
+			continue
+		}
+
+		command := strings.ToUpper(elements[0])
+		switch command {
+		case "REPLCONF":
+			if len(elements) >= 2 && strings.ToUpper(elements[1]) == "GETACK" {
+				ack := encodeRESPArray([]string{"REPLCONF", "ACK", strconv.FormatInt(offset, 10)})
+				if _, err := conn.Write(ack); err != nil {
+					log.Printf("Error sending REPLCONF ACK: %v", err)
+					return
+				}
+			}
+		default:
+			// Apply the write through the normal command path; replies to the
+			// master are not sent.
+			dispatch(nil, &ConnState{respVersion: 2}, value)
+		}
+	}
+}
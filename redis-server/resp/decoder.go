@@ -0,0 +1,185 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Decoder reads RESP values from a buffered stream. It understands both the
+// typed wire format (*+-:$%~,#(=_>) and the inline-command fallback used by
+// plain-text clients like telnet.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+func NewDecoder(r *bufio.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads and returns the next complete value. Pipelined requests are
+// supported naturally: Decode only consumes the bytes of one value, leaving
+// the rest buffered for the next call.
+func (d *Decoder) Decode() (Value, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return Value{}, err
+	}
+
+	switch Type(b) {
+	case SimpleString:
+		line, err := d.readLine()
+		return Value{Type: SimpleString, Str: line}, err
+	case Error:
+		line, err := d.readLine()
+		return Value{Type: Error, Str: line}, err
+	case Integer:
+		n, err := d.readInt()
+		return Value{Type: Integer, Int: n}, err
+	case BulkString:
+		return d.decodeBulkString()
+	case Array:
+		return d.decodeArray(Array)
+	case Map:
+		return d.decodeMap()
+	case Set:
+		return d.decodeArray(Set)
+	case Push:
+		return d.decodeArray(Push)
+	case Double:
+		line, err := d.readLine()
+		if err != nil {
+			return Value{}, err
+		}
+		f, err := strconv.ParseFloat(line, 64)
+		return Value{Type: Double, Double: f}, err
+	case Boolean:
+		line, err := d.readLine()
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{Type: Boolean, Bool: line == "t"}, nil
+	case BigNumber:
+		line, err := d.readLine()
+		return Value{Type: BigNumber, Str: line}, err
+	case Null:
+		_, err := d.readLine()
+		return Value{Type: Null}, err
+	case Verbatim:
+		return d.decodeVerbatim()
+	default:
+		// Not a recognized type byte: fall back to an inline command, the
+		// plain "PING\r\n" a telnet session would send.
+		if err := d.r.UnreadByte(); err != nil {
+			return Value{}, err
+		}
+		return d.decodeInline()
+	}
+}
+
+func (d *Decoder) readLine() (string, error) {
+	line, err := d.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (d *Decoder) readInt() (int64, error) {
+	line, err := d.readLine()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(line, 10, 64)
+}
+
+func (d *Decoder) decodeBulkString() (Value, error) {
+	length, err := d.readInt()
+	if err != nil {
+		return Value{}, err
+	}
+	if length < 0 {
+		return Value{Type: BulkString, BulkNil: true}, nil
+	}
+	data := make([]byte, length+2) // +2 for the trailing CRLF
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return Value{}, err
+	}
+	return Value{Type: BulkString, Bulk: string(data[:length])}, nil
+}
+
+func (d *Decoder) decodeVerbatim() (Value, error) {
+	length, err := d.readInt()
+	if err != nil {
+		return Value{}, err
+	}
+	data := make([]byte, length+2)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return Value{}, err
+	}
+	body := string(data[:length])
+	format, text := "txt", body
+	if len(body) >= 4 && body[3] == ':' {
+		format, text = body[:3], body[4:]
+	}
+	return Value{Type: Verbatim, Format: format, Bulk: text}, nil
+}
+
+func (d *Decoder) decodeArray(t Type) (Value, error) {
+	count, err := d.readInt()
+	if err != nil {
+		return Value{}, err
+	}
+	if count < 0 {
+		return Value{Type: t, Array: nil}, nil
+	}
+	elements := make([]Value, count)
+	for i := range elements {
+		v, err := d.Decode()
+		if err != nil {
+			return Value{}, err
+		}
+		elements[i] = v
+	}
+	return Value{Type: t, Array: elements}, nil
+}
+
+func (d *Decoder) decodeMap() (Value, error) {
+	count, err := d.readInt()
+	if err != nil {
+		return Value{}, err
+	}
+	entries := make([]MapEntry, count)
+	for i := range entries {
+		key, err := d.Decode()
+		if err != nil {
+			return Value{}, err
+		}
+		value, err := d.Decode()
+		if err != nil {
+			return Value{}, err
+		}
+		entries[i] = MapEntry{Key: key, Value: value}
+	}
+	return Value{Type: Map, Map: entries}, nil
+}
+
+// decodeInline parses a non-typed line into an Array of bulk strings, split
+// on whitespace the way `redis-cli`/telnet's inline protocol works.
+func (d *Decoder) decodeInline() (Value, error) {
+	line, err := d.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Value{Type: Array}, nil
+	}
+	elements := make([]Value, len(fields))
+	for i, field := range fields {
+		elements[i] = NewBulkString(field)
+	}
+	return Value{Type: Array, Array: elements}, nil
+}
+
@@ -0,0 +1,100 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Encoder writes RESP values to a buffered stream. Callers batch several
+// Encode calls (for pipelined replies) and call Flush once.
+type Encoder struct {
+	w *bufio.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+func (e *Encoder) Encode(v Value) error {
+	switch v.Type {
+	case SimpleString:
+		_, err := fmt.Fprintf(e.w, "+%s\r\n", v.Str)
+		return err
+	case Error:
+		_, err := fmt.Fprintf(e.w, "-%s\r\n", v.Str)
+		return err
+	case Integer:
+		_, err := fmt.Fprintf(e.w, ":%d\r\n", v.Int)
+		return err
+	case BulkString:
+		if v.BulkNil {
+			_, err := e.w.WriteString("$-1\r\n")
+			return err
+		}
+		_, err := fmt.Fprintf(e.w, "$%d\r\n%s\r\n", len(v.Bulk), v.Bulk)
+		return err
+	case Array:
+		return e.encodeArray('*', v.Array)
+	case Set:
+		return e.encodeArray('~', v.Array)
+	case Push:
+		return e.encodeArray('>', v.Array)
+	case Map:
+		if _, err := fmt.Fprintf(e.w, "%%%d\r\n", len(v.Map)); err != nil {
+			return err
+		}
+		for _, entry := range v.Map {
+			if err := e.Encode(entry.Key); err != nil {
+				return err
+			}
+			if err := e.Encode(entry.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Double:
+		_, err := fmt.Fprintf(e.w, ",%s\r\n", strconv.FormatFloat(v.Double, 'g', -1, 64))
+		return err
+	case Boolean:
+		flag := "f"
+		if v.Bool {
+			flag = "t"
+		}
+		_, err := fmt.Fprintf(e.w, "#%s\r\n", flag)
+		return err
+	case BigNumber:
+		_, err := fmt.Fprintf(e.w, "(%s\r\n", v.Str)
+		return err
+	case Verbatim:
+		body := v.Format + ":" + v.Bulk
+		_, err := fmt.Fprintf(e.w, "=%d\r\n%s\r\n", len(body), body)
+		return err
+	case Null:
+		_, err := e.w.WriteString("_\r\n")
+		return err
+	default:
+		return fmt.Errorf("resp: cannot encode value of type %q", rune(v.Type))
+	}
+}
+
+func (e *Encoder) encodeArray(prefix byte, elements []Value) error {
+	if elements == nil {
+		_, err := fmt.Fprintf(e.w, "%c-1\r\n", prefix)
+		return err
+	}
+	if _, err := fmt.Fprintf(e.w, "%c%d\r\n", prefix, len(elements)); err != nil {
+		return err
+	}
+	for _, el := range elements {
+		if err := e.Encode(el); err != nil {
+			return err
+		}
+	}
+	return nil
+}
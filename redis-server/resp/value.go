@@ -0,0 +1,106 @@
+// Package resp implements the RESP2/RESP3 wire protocol shared by every
+// command handler: a streaming Decoder that understands pipelining and
+// inline commands, a matching Encoder, and the Value type that carries a
+// reply between them.
+package resp
+
+import "strconv"
+
+// Type identifies which RESP value kind a Value holds, tagged with the byte
+// that introduces it on the wire.
+type Type byte
+
+const (
+	SimpleString Type = '+'
+	Error        Type = '-'
+	Integer      Type = ':'
+	BulkString   Type = '$'
+	Array        Type = '*'
+	Map          Type = '%'
+	Set          Type = '~'
+	Double       Type = ','
+	Boolean      Type = '#'
+	BigNumber    Type = '('
+	Verbatim     Type = '='
+	Null         Type = '_'
+	Push         Type = '>'
+)
+
+// MapEntry is one key/value pair of a RESP3 Map value.
+type MapEntry struct {
+	Key   Value
+	Value Value
+}
+
+// Value is a discriminated union over every RESP2/RESP3 value kind. Only the
+// fields relevant to Type are populated.
+type Value struct {
+	Type    Type
+	Str     string // SimpleString, Error, BigNumber
+	Int     int64  // Integer
+	Bulk    string // BulkString payload
+	BulkNil bool   // true for a nil bulk string ($-1\r\n)
+	Bool    bool   // Boolean
+	Double  float64
+	Format  string // Verbatim's 3-byte format tag, e.g. "txt"
+	Array   []Value
+	Map     []MapEntry
+}
+
+func NewSimpleString(s string) Value { return Value{Type: SimpleString, Str: s} }
+func NewError(s string) Value        { return Value{Type: Error, Str: s} }
+func NewInteger(n int64) Value       { return Value{Type: Integer, Int: n} }
+func NewBulkString(s string) Value   { return Value{Type: BulkString, Bulk: s} }
+func NewNullBulkString() Value       { return Value{Type: BulkString, BulkNil: true} }
+func NewNull() Value                 { return Value{Type: Null} }
+func NewBoolean(b bool) Value         { return Value{Type: Boolean, Bool: b} }
+func NewDouble(f float64) Value       { return Value{Type: Double, Double: f} }
+func NewBigNumber(s string) Value     { return Value{Type: BigNumber, Str: s} }
+func NewVerbatim(format, text string) Value {
+	return Value{Type: Verbatim, Format: format, Bulk: text}
+}
+
+func NewArray(elements ...Value) Value { return Value{Type: Array, Array: elements} }
+func NewSet(elements ...Value) Value   { return Value{Type: Set, Array: elements} }
+func NewPush(elements ...Value) Value  { return Value{Type: Push, Array: elements} }
+func NewMap(entries ...MapEntry) Value { return Value{Type: Map, Map: entries} }
+
+// FlatMap builds a Map from an alternating key/value slice, the shape most
+// command handlers already produce (e.g. CONFIG GET, HELLO).
+func FlatMap(flat []Value) Value {
+	entries := make([]MapEntry, 0, len(flat)/2)
+	for i := 0; i+1 < len(flat); i += 2 {
+		entries = append(entries, MapEntry{Key: flat[i], Value: flat[i+1]})
+	}
+	return NewMap(entries...)
+}
+
+// Flatten turns a Map back into an alternating key/value Array, used to
+// reply to RESP2 clients with data that is conceptually a map.
+func (v Value) Flatten() Value {
+	if v.Type != Map {
+		return v
+	}
+	flat := make([]Value, 0, len(v.Map)*2)
+	for _, entry := range v.Map {
+		flat = append(flat, entry.Key, entry.Value)
+	}
+	return NewArray(flat...)
+}
+
+// StringElements extracts the bulk/simple string payload of every element of
+// an Array value, the shape a client command arrives as.
+func (v Value) StringElements() []string {
+	elements := make([]string, 0, len(v.Array))
+	for _, el := range v.Array {
+		switch el.Type {
+		case BulkString:
+			elements = append(elements, el.Bulk)
+		case SimpleString, Error, BigNumber:
+			elements = append(elements, el.Str)
+		case Integer:
+			elements = append(elements, strconv.FormatInt(el.Int, 10))
+		}
+	}
+	return elements
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/madmecodes/go_codeCrafters/redis-server/pattern"
+)
+
+// paginateCursor implements the cursor plumbing shared by SCAN/HSCAN/SSCAN/
+// ZSCAN across every storage backend: on cursor 0 it takes a fresh snapshot
+// via freshItems, otherwise it resumes the snapshot left behind by a
+// previous call. It filters by MATCH, returns up to count items, and hands
+// back a fresh cursor id to resume from, or 0 once the snapshot is
+// exhausted.
+func paginateCursor(cursors *sync.Map, seq *int64, cursor int64, freshItems func() []string, match string, count int) (int64, []string) {
+	var remaining []string
+	if cursor == 0 {
+		remaining = freshItems()
+	} else {
+		v, ok := cursors.Load(cursor)
+		if !ok {
+			return 0, nil
+		}
+		cursors.Delete(cursor)
+		remaining = v.([]string)
+	}
+
+	if count <= 0 {
+		count = 10
+	}
+
+	matched := make([]string, 0, count)
+	i := 0
+	for i < len(remaining) && len(matched) < count {
+		if match == "" || pattern.Match(match, remaining[i]) {
+			matched = append(matched, remaining[i])
+		}
+		i++
+	}
+	remaining = remaining[i:]
+
+	if len(remaining) == 0 {
+		return 0, matched
+	}
+
+	id := atomic.AddInt64(seq, 1)
+	cursors.Store(id, remaining)
+	return id, matched
+}
+
+// paginatePairCursor is paginateCursor's counterpart for HSCAN/ZSCAN, whose
+// snapshot is flattened field/value (or member/score) pairs rather than
+// independent elements. MATCH is applied only to the first element of each
+// pair, COUNT counts pairs rather than elements, and a snapshot is only ever
+// truncated on a pair boundary, so resuming a cursor can never split a pair
+// across two replies or desync the field/value alternation.
+func paginatePairCursor(cursors *sync.Map, seq *int64, cursor int64, freshItems func() []string, match string, count int) (int64, []string) {
+	var remaining []string
+	if cursor == 0 {
+		remaining = freshItems()
+	} else {
+		v, ok := cursors.Load(cursor)
+		if !ok {
+			return 0, nil
+		}
+		cursors.Delete(cursor)
+		remaining = v.([]string)
+	}
+
+	if count <= 0 {
+		count = 10
+	}
+
+	matched := make([]string, 0, count*2)
+	pairs := 0
+	i := 0
+	for i+1 < len(remaining) && pairs < count {
+		name, value := remaining[i], remaining[i+1]
+		if match == "" || pattern.Match(match, name) {
+			matched = append(matched, name, value)
+		}
+		pairs++
+		i += 2
+	}
+	remaining = remaining[i:]
+
+	if len(remaining) == 0 {
+		return 0, matched
+	}
+
+	id := atomic.AddInt64(seq, 1)
+	cursors.Store(id, remaining)
+	return id, matched
+}
+
+// Scan implements SCAN cursor [MATCH pattern] [COUNT n] [TYPE t].
+func (s *MemStore) Scan(cursor int64, match string, count int, typeFilter string) (int64, []string) {
+	return paginateCursor(&s.scanCursors, &s.cursorSeq, cursor, func() []string {
+		keys := s.allKeys()
+		if typeFilter == "" {
+			return keys
+		}
+		filtered := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if s.Type(key) == typeFilter {
+				filtered = append(filtered, key)
+			}
+		}
+		return filtered
+	}, match, count)
+}
+
+// HScan implements HSCAN key cursor [MATCH pattern] [COUNT n], iterating the
+// field/value pairs of a hash key once hash-writing commands populate one.
+func (s *MemStore) HScan(key string, cursor int64, match string, count int) (int64, []string) {
+	return paginatePairCursor(&s.scanCursors, &s.cursorSeq, cursor, func() []string {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		item, exists := s.data[key]
+		if !exists || item.valueType != TypeHash {
+			return nil
+		}
+		flat := make([]string, 0, len(item.hash)*2)
+		for field, value := range item.hash {
+			flat = append(flat, field, value)
+		}
+		return flat
+	}, match, count)
+}
+
+// SScan implements SSCAN key cursor [MATCH pattern] [COUNT n].
+func (s *MemStore) SScan(key string, cursor int64, match string, count int) (int64, []string) {
+	return paginateCursor(&s.scanCursors, &s.cursorSeq, cursor, func() []string {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		item, exists := s.data[key]
+		if !exists || item.valueType != TypeSet {
+			return nil
+		}
+		return append([]string(nil), item.set...)
+	}, match, count)
+}
+
+// ZScan implements ZSCAN key cursor [MATCH pattern] [COUNT n], returning
+// member/score pairs the same way HSCAN returns field/value pairs.
+func (s *MemStore) ZScan(key string, cursor int64, match string, count int) (int64, []string) {
+	return paginatePairCursor(&s.scanCursors, &s.cursorSeq, cursor, func() []string {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		item, exists := s.data[key]
+		if !exists || item.valueType != TypeZSet {
+			return nil
+		}
+		flat := make([]string, 0, len(item.zset)*2)
+		for _, member := range item.zset {
+			flat = append(flat, member.Member, strconv.FormatFloat(member.Score, 'g', -1, 64))
+		}
+		return flat
+	}, match, count)
+}
@@ -8,38 +8,117 @@ import (
 	"log"
 	"net"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/madmecodes/go_codeCrafters/redis-server/aof"
+	"github.com/madmecodes/go_codeCrafters/redis-server/pattern"
+	"github.com/madmecodes/go_codeCrafters/redis-server/resp"
 )
 
 type Config struct {
-	dir        string
-	dbfilename string
+	dir         string
+	dbfilename  string
+	replicaof   string
+	port        string
+	appendfsync string
+	storage     string
 }
 
+const aofFilename = "appendonly.aof"
+
+// aofWriter is nil until main sets it up; appendToAOF no-ops until then.
+var aofWriter *aof.Writer
+
+// loadingAOF suppresses re-appending to the log while replaying it at
+// startup, since every command it replays is already recorded there.
+var loadingAOF bool
+
 var config Config
 
 type StoreItem struct {
+	valueType byte
 	value     string
+	list      []string
+	hash      map[string]string
+	set       []string
+	zset      []ZSetMember
 	hasExpiry bool
 	expiresAt time.Time
 }
 
-type Store struct {
+// Storage is implemented by every store backend. MemStore keeps the whole
+// keyspace in a Go map; DiskStore persists it to an embedded LSM so a
+// dataset larger than RAM still fits. The backend is selected at startup by
+// --storage and everything else in this file talks to it through this
+// interface, except HSCAN/SSCAN/ZSCAN, which only MemStore implements.
+type Storage interface {
+	Get(key string) (string, bool)
+	Set(key, value string, px int64)
+	SetList(key string, values []string, px int64)
+	SetHash(key string, fields map[string]string, px int64)
+	SetSet(key string, members []string, px int64)
+	SetZSet(key string, members []ZSetMember, px int64)
+	GetList(key string) ([]string, bool)
+	GetHash(key string) (map[string]string, bool)
+	GetSet(key string) ([]string, bool)
+	GetZSet(key string) ([]ZSetMember, bool)
+	Delete(key string) bool
+	Keys(pattern string) []string
+	Scan(cursor int64, match string, count int, typeFilter string) (int64, []string)
+	Expire(key string, px int64) bool
+	TTL(key string) (int64, bool)
+	Type(key string) string
+	Dump() []KeyValue
+}
+
+// typeName maps an RDB value-type byte to the Redis type name reported by
+// TYPE and matched against SCAN ... TYPE, shared by every backend.
+func typeName(valueType byte) string {
+	switch valueType {
+	case TypeString:
+		return "string"
+	case TypeList, TypeListQuicklist, TypeListQuicklist2:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeHash, TypeHashZiplist, TypeHashListpack:
+		return "hash"
+	case TypeZSet, TypeZSetZiplist, TypeZSetListpack:
+		return "zset"
+	default:
+		return ""
+	}
+}
+
+// MemStore is the map-backed Storage implementation.
+type MemStore struct {
 	mu   sync.RWMutex
 	data map[string]StoreItem
+
+	// scanCursors holds the remaining-keys snapshot for an in-progress
+	// SCAN/HSCAN/SSCAN/ZSCAN, keyed by the cursor id handed back to the
+	// client. cursorSeq hands out those ids.
+	scanCursors sync.Map
+	cursorSeq   int64
 }
 
-var store = &Store{
-	data: make(map[string]StoreItem),
+// NewMemStore creates an empty map-backed store.
+func NewMemStore() *MemStore {
+	return &MemStore{data: make(map[string]StoreItem)}
 }
 
-func (s *Store) Set(key, value string, px int64) {
+// store is the active backend, chosen in main() by --storage.
+var store Storage
+
+func (s *MemStore) Set(key, value string, px int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	item := StoreItem{
+		valueType: TypeString,
 		value:     value,
 		hasExpiry: px > 0,
 	}
@@ -49,7 +128,103 @@ func (s *Store) Set(key, value string, px int64) {
 	s.data[key] = item
 }
 
-func (s *Store) Get(key string) (string, bool) {
+// setExpiry fills in the hasExpiry/expiresAt fields shared by every
+// Set* method from a PX millisecond argument, mirroring Set.
+func setExpiry(item *StoreItem, px int64) {
+	item.hasExpiry = px > 0
+	if px > 0 {
+		item.expiresAt = time.Now().Add(time.Duration(px) * time.Millisecond)
+	}
+}
+
+// remainingPX returns key's current TTL in milliseconds, or 0 if it has none
+// or doesn't exist, so a read-modify-write command like RPUSH/SADD/HSET/ZADD
+// can carry an existing expiry forward through SetList/SetSet/SetHash/
+// SetZSet instead of clearing it.
+func remainingPX(key string) int64 {
+	ttl, exists := store.TTL(key)
+	if exists && ttl > 0 {
+		return ttl
+	}
+	return 0
+}
+
+func (s *MemStore) SetList(key string, values []string, px int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item := StoreItem{valueType: TypeList, list: values}
+	setExpiry(&item, px)
+	s.data[key] = item
+}
+
+func (s *MemStore) SetHash(key string, fields map[string]string, px int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item := StoreItem{valueType: TypeHash, hash: fields}
+	setExpiry(&item, px)
+	s.data[key] = item
+}
+
+func (s *MemStore) SetSet(key string, members []string, px int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item := StoreItem{valueType: TypeSet, set: members}
+	setExpiry(&item, px)
+	s.data[key] = item
+}
+
+func (s *MemStore) SetZSet(key string, members []ZSetMember, px int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item := StoreItem{valueType: TypeZSet, zset: members}
+	setExpiry(&item, px)
+	s.data[key] = item
+}
+
+// GetList, GetHash, GetSet, and GetZSet return a key's current collection so
+// RPUSH/HSET/SADD/ZADD can read-modify-write it through SetList/SetHash/
+// SetSet/SetZSet, the same way Get backs SET.
+func (s *MemStore) GetList(key string) ([]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, exists := s.data[key]
+	if !exists || item.valueType != TypeList {
+		return nil, false
+	}
+	return item.list, true
+}
+
+func (s *MemStore) GetHash(key string) (map[string]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, exists := s.data[key]
+	if !exists || item.valueType != TypeHash {
+		return nil, false
+	}
+	return item.hash, true
+}
+
+func (s *MemStore) GetSet(key string) ([]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, exists := s.data[key]
+	if !exists || item.valueType != TypeSet {
+		return nil, false
+	}
+	return item.set, true
+}
+
+func (s *MemStore) GetZSet(key string) ([]ZSetMember, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, exists := s.data[key]
+	if !exists || item.valueType != TypeZSet {
+		return nil, false
+	}
+	return item.zset, true
+}
+
+func (s *MemStore) Get(key string) (string, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	item, exists := s.data[key]
@@ -57,44 +232,154 @@ func (s *Store) Get(key string) (string, bool) {
 		return "", false
 	}
 	if item.hasExpiry && time.Now().After(item.expiresAt) {
-		go s.deleteKey(key)
+		go s.Delete(key)
 		return "", false
 	}
 	return item.value, true
 }
 
-func (s *Store) deleteKey(key string) {
+// Type returns the Redis type name of key ("string", "list", "hash", "set",
+// "zset"), or "" if the key does not exist.
+func (s *MemStore) Type(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, exists := s.data[key]
+	if !exists {
+		return ""
+	}
+	return typeName(item.valueType)
+}
+
+func (s *MemStore) Delete(key string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if _, exists := s.data[key]; !exists {
+		return false
+	}
 	delete(s.data, key)
+	return true
+}
+
+// Expire sets key's expiry to px milliseconds from now, leaving its value
+// untouched. It reports whether key existed.
+func (s *MemStore) Expire(key string, px int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, exists := s.data[key]
+	if !exists {
+		return false
+	}
+	setExpiry(&item, px)
+	s.data[key] = item
+	return true
+}
+
+// TTL returns key's remaining time to live in milliseconds, -1 if it exists
+// but has no expiry, or (0, false) if it does not exist.
+func (s *MemStore) TTL(key string) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, exists := s.data[key]
+	if !exists {
+		return 0, false
+	}
+	if !item.hasExpiry {
+		return -1, true
+	}
+	return time.Until(item.expiresAt).Milliseconds(), true
+}
+
+// Dump builds the list of key-value pairs needed to persist the store to an
+// RDB file via Writer.Write, skipping keys that have already expired.
+func (s *MemStore) Dump() []KeyValue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	pairs := make([]KeyValue, 0, len(s.data))
+	for key, item := range s.data {
+		if item.hasExpiry && now.After(item.expiresAt) {
+			continue
+		}
+		pair := KeyValue{
+			Key:       key,
+			Type:      item.valueType,
+			Str:       item.value,
+			List:      item.list,
+			Hash:      item.hash,
+			Set:       item.set,
+			ZSet:      item.zset,
+			HasExpiry: item.hasExpiry,
+			ExpiresAt: item.expiresAt,
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
 }
 
 func main() {
 	flag.StringVar(&config.dir, "dir", "", "Directory for RDB file")
 	flag.StringVar(&config.dbfilename, "dbfilename", "", "Name of RDB file")
+	flag.StringVar(&config.port, "port", "6379", "Port to listen on")
+	flag.StringVar(&config.replicaof, "replicaof", "", "Master \"<host> <port>\" to replicate from")
+	flag.StringVar(&config.appendfsync, "appendfsync", "everysec", "AOF fsync policy: always, everysec, or no")
+	flag.StringVar(&config.storage, "storage", "memory", "Storage backend: memory or disk")
 	flag.Parse()
 
-	// Load RDB file at startup
+	switch config.storage {
+	case "disk":
+		diskStore, err := NewDiskStore(filepath.Join(config.dir, "data.leveldb"))
+		if err != nil {
+			log.Fatalf("Error opening disk store: %v", err)
+		}
+		store = diskStore
+	default:
+		store = NewMemStore()
+	}
+
+	initReplication()
+
+	// Load RDB file at startup, streaming each pair straight into the store
+	// rather than collecting them into a slice first, so a disk-backed store
+	// can load a dump far larger than RAM.
 	reader := NewReader(config.dir, config.dbfilename)
-	pairs, err := reader.Read()
-	if err != nil {
+	if err := reader.ReadInto(loadPairIntoStore); err != nil {
 		log.Printf("Error reading RDB file: %v", err)
-	} else if pairs != nil {
-		for _, pair := range pairs {
-			var px int64 = 0
-			if pair.HasExpiry {
-				px = time.Until(pair.ExpiresAt).Milliseconds()
-				if px <= 0 {
-					continue
-				}
+	}
+
+	// Replay the AOF on top of the RDB snapshot, then start appending to it.
+	aofReader := aof.NewReader(config.dir, aofFilename)
+	commands, err := aofReader.Read()
+	if err != nil {
+		log.Printf("Error reading AOF file: %v", err)
+	} else {
+		loadingAOF = true
+		for _, elements := range commands {
+			if len(elements) == 0 {
+				continue
 			}
-			store.Set(pair.Key, pair.Value, px)
+			dispatch(nil, &ConnState{}, commandValue(elements))
+		}
+		loadingAOF = false
+	}
+
+	writer, err := aof.NewWriter(config.dir, aofFilename, config.appendfsync)
+	if err != nil {
+		log.Printf("Error opening AOF file: %v", err)
+	} else {
+		aofWriter = writer
+		if config.appendfsync == "everysec" {
+			go aofWriter.RunBackgroundFsync(time.Second)
 		}
 	}
 
-	l, err := net.Listen("tcp", "0.0.0.0:6379")
+	if config.replicaof != "" {
+		go startReplicaOf(config.replicaof)
+	}
+
+	l, err := net.Listen("tcp", "0.0.0.0:"+config.port)
 	if err != nil {
-		fmt.Println("Failed to bind to port 6379")
+		fmt.Printf("Failed to bind to port %s\n", config.port)
 		os.Exit(1)
 	}
 	defer l.Close()
@@ -109,165 +394,608 @@ func main() {
 	}
 }
 
+// loadPairIntoStore applies one key-value pair parsed from an RDB file to
+// the active store, skipping it if it has already expired. Both the startup
+// RDB load and a replica's FULLRESYNC load share it.
+func loadPairIntoStore(pair KeyValue) error {
+	var px int64
+	if pair.HasExpiry {
+		px = time.Until(pair.ExpiresAt).Milliseconds()
+		if px <= 0 {
+			return nil
+		}
+	}
+	switch pair.Type {
+	case TypeString:
+		store.Set(pair.Key, pair.Str, px)
+	case TypeList, TypeListQuicklist, TypeListQuicklist2:
+		store.SetList(pair.Key, pair.List, px)
+	case TypeSet:
+		store.SetSet(pair.Key, pair.Set, px)
+	case TypeHash, TypeHashZiplist, TypeHashListpack:
+		store.SetHash(pair.Key, pair.Hash, px)
+	case TypeZSet, TypeZSetZiplist, TypeZSetListpack:
+		store.SetZSet(pair.Key, pair.ZSet, px)
+	}
+	return nil
+}
+
+// ConnState tracks the per-connection protocol negotiated via HELLO, so
+// replies can be framed as RESP2 or RESP3 without a global switch.
+type ConnState struct {
+	respVersion int
+}
+
+// handleRequest decodes and dispatches every request on conn. Because the
+// decoder only consumes the bytes of one value at a time, pipelined
+// requests already queued in the reader's buffer are drained and dispatched
+// before a single flush sends all of their replies at once.
 func handleRequest(conn net.Conn) {
 	defer conn.Close()
 	reader := bufio.NewReader(conn)
+	decoder := resp.NewDecoder(reader)
+	encoder := resp.NewEncoder(conn)
+	state := &ConnState{respVersion: 2}
 
 	for {
-		firstLine, err := reader.ReadString('\n')
+		request, err := decoder.Decode()
 		if err != nil {
 			if err != io.EOF {
-				log.Printf("Error reading first line: %v\n", err)
+				log.Printf("Error decoding request: %v\n", err)
 			}
 			return
 		}
 
-		if !strings.HasPrefix(firstLine, "*") {
-			log.Println("Invalid RESP format: expected array")
-			return
+		reply, hasReply := dispatch(conn, state, request)
+		if hasReply {
+			if err := encoder.Encode(reply); err != nil {
+				log.Printf("Error encoding response: %v\n", err)
+				return
+			}
+		}
+
+		if reader.Buffered() == 0 {
+			if err := encoder.Flush(); err != nil {
+				log.Printf("Error flushing response: %v\n", err)
+				return
+			}
+		}
+	}
+}
+
+// dispatch executes one decoded request and returns the value to send back,
+// if any. PSYNC and REPLCONF ACK handle their own framing (a raw FULLRESYNC
+// handshake, or no reply at all) and report hasReply=false.
+func dispatch(conn net.Conn, state *ConnState, request resp.Value) (reply resp.Value, hasReply bool) {
+	elements := request.StringElements()
+	if len(elements) == 0 {
+		return resp.Value{}, false
+	}
+
+	command := strings.ToUpper(elements[0])
+	switch command {
+	case "PING":
+		return resp.NewSimpleString("PONG"), true
+
+	case "ECHO":
+		if len(elements) < 2 {
+			return resp.NewError("ERR wrong number of arguments for 'echo' command"), true
+		}
+		return resp.NewBulkString(elements[1]), true
+
+	case "SET":
+		return dispatchSet(elements)
+
+	case "GET":
+		if len(elements) != 2 {
+			return resp.NewError("ERR wrong number of arguments for 'get' command"), true
+		}
+		value, exists := store.Get(elements[1])
+		if !exists {
+			return resp.NewNullBulkString(), true
 		}
+		return resp.NewBulkString(value), true
 
-		arrayLen, err := strconv.Atoi(strings.TrimSuffix(firstLine[1:], "\r\n"))
+	case "CONFIG":
+		if len(elements) >= 3 && strings.ToUpper(elements[1]) == "GET" {
+			return handleConfigGet(state, elements[2]), true
+		}
+		return resp.NewError("ERR wrong number of arguments"), true
+
+	case "KEYS":
+		if len(elements) != 2 {
+			return resp.NewError("ERR wrong number of arguments for 'keys' command"), true
+		}
+		keys := store.Keys(elements[1])
+		values := make([]resp.Value, len(keys))
+		for i, key := range keys {
+			values[i] = resp.NewBulkString(key)
+		}
+		return resp.NewArray(values...), true
+
+	case "SCAN":
+		if len(elements) < 2 {
+			return resp.NewError("ERR wrong number of arguments for 'scan' command"), true
+		}
+		cursor, err := strconv.ParseInt(elements[1], 10, 64)
 		if err != nil {
-			log.Printf("Error parsing array length: %v\n", err)
-			return
+			return resp.NewError("ERR invalid cursor"), true
 		}
+		match, count, typeFilter, err := parseScanOptions(elements[2:])
+		if err != nil {
+			return resp.NewError("ERR " + err.Error()), true
+		}
+		next, keys := store.Scan(cursor, match, count, typeFilter)
+		return scanReply(next, keys), true
 
-		elements := make([]string, 0, arrayLen)
-		for i := 0; i < arrayLen; i++ {
-			bulkLen, err := reader.ReadString('\n')
-			if err != nil {
-				log.Printf("Error reading bulk length: %v\n", err)
-				return
+	case "HSCAN", "SSCAN", "ZSCAN":
+		if len(elements) < 3 {
+			return resp.NewError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command))), true
+		}
+		memStore, ok := store.(*MemStore)
+		if !ok {
+			return resp.NewError(fmt.Sprintf("ERR %s is not supported by the disk storage backend", command)), true
+		}
+		key := elements[1]
+		cursor, err := strconv.ParseInt(elements[2], 10, 64)
+		if err != nil {
+			return resp.NewError("ERR invalid cursor"), true
+		}
+		match, count, _, err := parseScanOptions(elements[3:])
+		if err != nil {
+			return resp.NewError("ERR " + err.Error()), true
+		}
+		var next int64
+		var items []string
+		switch command {
+		case "HSCAN":
+			next, items = memStore.HScan(key, cursor, match, count)
+		case "SSCAN":
+			next, items = memStore.SScan(key, cursor, match, count)
+		case "ZSCAN":
+			next, items = memStore.ZScan(key, cursor, match, count)
+		}
+		return scanReply(next, items), true
+
+	case "REPLCONF":
+		if len(elements) >= 2 && strings.ToUpper(elements[1]) == "ACK" {
+			if len(elements) >= 3 {
+				if offset, err := strconv.ParseInt(elements[2], 10, 64); err == nil {
+					updateReplicaAck(conn, offset)
+				}
 			}
+			return resp.Value{}, false
+		}
+		return resp.NewSimpleString("OK"), true
 
-			if !strings.HasPrefix(bulkLen, "$") {
-				log.Println("Invalid RESP format: expected bulk string")
-				return
+	case "PSYNC":
+		if err := sendFullResync(conn); err == nil {
+			registerReplica(conn)
+		} else {
+			log.Printf("Error sending FULLRESYNC: %v\n", err)
+		}
+		return resp.Value{}, false
+
+	case "INFO":
+		section := ""
+		if len(elements) > 1 {
+			section = strings.ToLower(elements[1])
+		}
+		if section == "" || section == "replication" {
+			return resp.NewBulkString(replicationInfo()), true
+		}
+		return resp.NewBulkString(""), true
+
+	case "WAIT":
+		if len(elements) != 3 {
+			return resp.NewError("ERR wrong number of arguments for 'wait' command"), true
+		}
+		numReplicas, _ := strconv.Atoi(elements[1])
+		timeoutMs, _ := strconv.Atoi(elements[2])
+		return resp.NewInteger(int64(waitForReplicas(numReplicas, timeoutMs))), true
+
+	case "RPUSH":
+		if len(elements) < 3 {
+			return resp.NewError("ERR wrong number of arguments for 'rpush' command"), true
+		}
+		key := elements[1]
+		existing, _ := store.GetList(key)
+		values := append(append([]string(nil), existing...), elements[2:]...)
+		store.SetList(key, values, remainingPX(key))
+		propagateToReplicas(elements)
+		appendToAOF(elements)
+		return resp.NewInteger(int64(len(values))), true
+
+	case "SADD":
+		if len(elements) < 3 {
+			return resp.NewError("ERR wrong number of arguments for 'sadd' command"), true
+		}
+		key := elements[1]
+		existing, _ := store.GetSet(key)
+		members := append([]string(nil), existing...)
+		seen := make(map[string]bool, len(members))
+		for _, member := range members {
+			seen[member] = true
+		}
+		added := 0
+		for _, member := range elements[2:] {
+			if !seen[member] {
+				seen[member] = true
+				members = append(members, member)
+				added++
 			}
+		}
+		store.SetSet(key, members, remainingPX(key))
+		propagateToReplicas(elements)
+		appendToAOF(elements)
+		return resp.NewInteger(int64(added)), true
 
-			length, err := strconv.Atoi(strings.TrimSuffix(bulkLen[1:], "\r\n"))
-			if err != nil {
-				log.Printf("Error parsing bulk length: %v\n", err)
-				return
+	case "HSET":
+		if len(elements) < 4 || len(elements)%2 != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'hset' command"), true
+		}
+		key := elements[1]
+		existing, _ := store.GetHash(key)
+		fields := make(map[string]string, len(existing))
+		for field, value := range existing {
+			fields[field] = value
+		}
+		added := 0
+		for i := 2; i+1 < len(elements); i += 2 {
+			if _, exists := fields[elements[i]]; !exists {
+				added++
 			}
+			fields[elements[i]] = elements[i+1]
+		}
+		store.SetHash(key, fields, remainingPX(key))
+		propagateToReplicas(elements)
+		appendToAOF(elements)
+		return resp.NewInteger(int64(added)), true
 
-			bulkData := make([]byte, length+2)
-			_, err = io.ReadFull(reader, bulkData)
+	case "ZADD":
+		if len(elements) < 4 || len(elements)%2 != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'zadd' command"), true
+		}
+		key := elements[1]
+		existing, _ := store.GetZSet(key)
+		members := append([]ZSetMember(nil), existing...)
+		index := make(map[string]int, len(members))
+		for i, member := range members {
+			index[member.Member] = i
+		}
+		added := 0
+		for i := 2; i+1 < len(elements); i += 2 {
+			score, err := strconv.ParseFloat(elements[i], 64)
 			if err != nil {
-				log.Printf("Error reading bulk data: %v\n", err)
-				return
+				return resp.NewError("ERR value is not a valid float"), true
 			}
+			member := elements[i+1]
+			if idx, exists := index[member]; exists {
+				members[idx].Score = score
+			} else {
+				index[member] = len(members)
+				members = append(members, ZSetMember{Member: member, Score: score})
+				added++
+			}
+		}
+		store.SetZSet(key, members, remainingPX(key))
+		propagateToReplicas(elements)
+		appendToAOF(elements)
+		return resp.NewInteger(int64(added)), true
 
-			elements = append(elements, string(bulkData[:length]))
+	case "PEXPIREAT":
+		if len(elements) != 3 {
+			return resp.NewError("ERR wrong number of arguments for 'pexpireat' command"), true
+		}
+		atMs, err := strconv.ParseInt(elements[2], 10, 64)
+		if err != nil {
+			return resp.NewError("ERR value is not an integer or out of range"), true
+		}
+		px := atMs - time.Now().UnixMilli()
+		var ok bool
+		if px <= 0 {
+			ok = store.Delete(elements[1])
+		} else {
+			ok = store.Expire(elements[1], px)
 		}
+		propagateToReplicas(elements)
+		appendToAOF(elements)
+		if ok {
+			return resp.NewInteger(1), true
+		}
+		return resp.NewInteger(0), true
 
-		if len(elements) > 0 {
-			command := strings.ToUpper(elements[0])
-			switch command {
-			case "PING":
-				_, err = conn.Write([]byte("+PONG\r\n"))
-			case "ECHO":
-				if len(elements) < 2 {
-					_, err = conn.Write([]byte("-ERR wrong number of arguments for 'echo' command\r\n"))
-					continue
-				}
-				response := fmt.Sprintf("$%d\r\n%s\r\n", len(elements[1]), elements[1])
-				_, err = conn.Write([]byte(response))
-			case "SET":
-				if len(elements) < 3 {
-					_, err = conn.Write([]byte("-ERR wrong number of arguments for 'set' command\r\n"))
-					continue
-				}
+	case "SAVE", "BGSAVE":
+		writer := NewWriter(config.dir, config.dbfilename)
+		if err := writer.Write(store.Dump()); err != nil {
+			log.Printf("Error saving RDB file: %v\n", err)
+			return resp.NewError("ERR failed to save RDB file"), true
+		}
+		if command == "BGSAVE" {
+			return resp.NewSimpleString("Background saving started"), true
+		}
+		return resp.NewSimpleString("OK"), true
 
-				key, value := elements[1], elements[2]
-				var px int64 = 0
-
-				if len(elements) > 3 && strings.ToUpper(elements[3]) == "PX" {
-					if len(elements) < 5 {
-						_, err = conn.Write([]byte("-ERR syntax error\r\n"))
-						continue
-					}
-					px, err = strconv.ParseInt(elements[4], 10, 64)
-					if err != nil {
-						_, err = conn.Write([]byte("-ERR invalid expire time in 'set' command\r\n"))
-						continue
-					}
-				}
+	case "BGREWRITEAOF":
+		if err := rewriteAOF(); err != nil {
+			log.Printf("Error rewriting AOF: %v\n", err)
+			return resp.NewError("ERR failed to rewrite AOF"), true
+		}
+		return resp.NewSimpleString("Background append only file rewriting started"), true
 
-				store.Set(key, value, px)
-				_, err = conn.Write([]byte("+OK\r\n"))
-			case "GET":
-				if len(elements) != 2 {
-					_, err = conn.Write([]byte("-ERR wrong number of arguments for 'get' command\r\n"))
-					continue
-				}
-				value, exists := store.Get(elements[1])
-				if !exists {
-					_, err = conn.Write([]byte("$-1\r\n"))
-				} else {
-					response := fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
-					_, err = conn.Write([]byte(response))
-				}
-			case "CONFIG":
-				if len(elements) >= 3 && strings.ToUpper(elements[1]) == "GET" {
-					handleConfigGet(conn, elements[2])
-				} else {
-					_, err = conn.Write([]byte("-ERR Wrong number of arguments\r\n"))
-				}
-			case "KEYS":
-				if len(elements) != 2 {
-					_, err = conn.Write([]byte("-ERR wrong number of arguments for 'keys' command\r\n"))
-					continue
-				}
+	case "HELLO":
+		return handleHello(state, elements), true
 
-				pattern := elements[1]
-				if pattern != "*" {
-					_, err = conn.Write([]byte("-ERR unsupported pattern\r\n"))
-					continue
-				}
+	default:
+		return resp.NewError("ERR unknown command"), true
+	}
+}
 
-				// Get all keys from store
-				keys := getAllKeys(store)
-				response := fmt.Sprintf("*%d\r\n", len(keys))
-				for _, key := range keys {
-					response += fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
-				}
+func dispatchSet(elements []string) (resp.Value, bool) {
+	if len(elements) < 3 {
+		return resp.NewError("ERR wrong number of arguments for 'set' command"), true
+	}
+
+	key, value := elements[1], elements[2]
+	var px int64 = 0
+
+	if len(elements) > 3 && strings.ToUpper(elements[3]) == "PX" {
+		if len(elements) < 5 {
+			return resp.NewError("ERR syntax error"), true
+		}
+		parsed, err := strconv.ParseInt(elements[4], 10, 64)
+		if err != nil {
+			return resp.NewError("ERR invalid expire time in 'set' command"), true
+		}
+		px = parsed
+	}
+
+	store.Set(key, value, px)
+	propagateToReplicas(elements)
+	appendToAOF(elements)
+	return resp.NewSimpleString("OK"), true
+}
+
+// commandValue builds the resp.Value a client's SET/GET/etc. request decodes
+// to, from its already-split elements. It lets AOF replay feed commands
+// straight into dispatch without re-parsing anything.
+func commandValue(elements []string) resp.Value {
+	values := make([]resp.Value, len(elements))
+	for i, el := range elements {
+		values[i] = resp.NewBulkString(el)
+	}
+	return resp.NewArray(values...)
+}
+
+// appendToAOF records a mutating command to the append-only log, unless
+// we're currently replaying that very log at startup.
+func appendToAOF(elements []string) {
+	if loadingAOF || aofWriter == nil {
+		return
+	}
+	if err := aofWriter.Append(encodeRESPArray(elements)); err != nil {
+		log.Printf("Error appending to AOF: %v\n", err)
+	}
+}
+
+// rewriteAOF implements BGREWRITEAOF: it snapshots the store into the
+// smallest set of commands that reproduce it, writes them to a fresh file,
+// and atomically swaps it in for the log the writer has been appending to.
+func rewriteAOF() error {
+	if aofWriter == nil {
+		return nil
+	}
+
+	// Start queueing concurrent Appends before taking the snapshot, not
+	// after: otherwise a write landing between Dump() and the rename below
+	// lands in the old file right as it's renamed away, caught by neither
+	// the new file nor FinishRewrite's queue.
+	aofWriter.BeginRewrite()
 
-				_, err = conn.Write([]byte(response))
-			default:
-				_, err = conn.Write([]byte("-ERR unknown command\r\n"))
+	// If the rewrite fails, go back to appending to the existing log and
+	// flush whatever was queued in the meantime.
+	finalPath := filepath.Join(config.dir, aofFilename)
+	rewritten := false
+	defer func() {
+		if !rewritten {
+			if err := aofWriter.FinishRewrite(finalPath); err != nil {
+				log.Printf("Error resuming AOF after failed rewrite: %v\n", err)
 			}
+		}
+	}()
 
-			if err != nil {
-				log.Printf("Error writing response: %v\n", err)
-				return
+	tmpPath := filepath.Join(config.dir, aofFilename+".tmp")
+	tmpFile, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(tmpFile)
+	for _, pair := range store.Dump() {
+		for _, cmd := range rewriteCommandsFor(pair) {
+			if _, err := bw.Write(encodeRESPArray(cmd)); err != nil {
+				tmpFile.Close()
+				return err
 			}
 		}
 	}
+	if err := bw.Flush(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return err
+	}
+	rewritten = true
+	return aofWriter.FinishRewrite(finalPath)
+}
+
+// rewriteCommandsFor returns the command(s) that recreate pair. A string is
+// one SET (with an inline PX); a collection needs its bulk-load command
+// (RPUSH/SADD/HSET/ZADD) plus a separate PEXPIREAT to restore the expiry,
+// since none of those commands take one inline.
+func rewriteCommandsFor(pair KeyValue) [][]string {
+	switch pair.Type {
+	case TypeString:
+		cmd := []string{"SET", pair.Key, pair.Str}
+		if pair.HasExpiry {
+			cmd = append(cmd, "PX", strconv.FormatInt(time.Until(pair.ExpiresAt).Milliseconds(), 10))
+		}
+		return [][]string{cmd}
+
+	case TypeList, TypeListQuicklist, TypeListQuicklist2:
+		if len(pair.List) == 0 {
+			return nil
+		}
+		return withExpiry(pair, append([]string{"RPUSH", pair.Key}, pair.List...))
+
+	case TypeSet:
+		if len(pair.Set) == 0 {
+			return nil
+		}
+		return withExpiry(pair, append([]string{"SADD", pair.Key}, pair.Set...))
+
+	case TypeHash, TypeHashZiplist, TypeHashListpack:
+		if len(pair.Hash) == 0 {
+			return nil
+		}
+		cmd := []string{"HSET", pair.Key}
+		for field, value := range pair.Hash {
+			cmd = append(cmd, field, value)
+		}
+		return withExpiry(pair, cmd)
+
+	case TypeZSet, TypeZSetZiplist, TypeZSetListpack:
+		if len(pair.ZSet) == 0 {
+			return nil
+		}
+		cmd := []string{"ZADD", pair.Key}
+		for _, member := range pair.ZSet {
+			cmd = append(cmd, strconv.FormatFloat(member.Score, 'g', -1, 64), member.Member)
+		}
+		return withExpiry(pair, cmd)
+
+	default:
+		return nil
+	}
 }
 
-func handleConfigGet(conn net.Conn, param string) {
+// withExpiry bundles a collection's bulk-load command with a trailing
+// PEXPIREAT if pair has one, since RPUSH/SADD/HSET/ZADD have no inline way
+// to set an expiry the way SET's PX does.
+func withExpiry(pair KeyValue, cmd []string) [][]string {
+	cmds := [][]string{cmd}
+	if pair.HasExpiry {
+		cmds = append(cmds, []string{"PEXPIREAT", pair.Key, strconv.FormatInt(pair.ExpiresAt.UnixMilli(), 10)})
+	}
+	return cmds
+}
+
+// handleConfigGet shapes its reply to the connection's negotiated protocol:
+// a RESP3 client gets a real Map, a RESP2 client gets the equivalent
+// flattened key/value Array.
+func handleConfigGet(state *ConnState, param string) resp.Value {
 	var value string
 	switch strings.ToLower(param) {
 	case "dir":
 		value = config.dir
 	case "dbfilename":
 		value = config.dbfilename
-	default:
-		value = ""
 	}
-	response := fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
-		len(param), param,
-		len(value), value)
 
-	_, err := conn.Write([]byte(response))
-	if err != nil {
-		log.Printf("Error writing config GET response: %v", err)
+	pair := []resp.Value{resp.NewBulkString(param), resp.NewBulkString(value)}
+	if state.respVersion >= 3 {
+		return resp.FlatMap(pair)
 	}
+	return resp.NewArray(pair...)
 }
 
-func getAllKeys(s *Store) []string {
+// handleHello negotiates the RESP protocol version for the connection and
+// replies with the server/connection info real Redis clients expect.
+func handleHello(state *ConnState, elements []string) resp.Value {
+	version := state.respVersion
+	if len(elements) > 1 {
+		parsed, err := strconv.Atoi(elements[1])
+		if err != nil || (parsed != 2 && parsed != 3) {
+			return resp.NewError("NOPROTO unsupported protocol version")
+		}
+		version = parsed
+	}
+	state.respVersion = version
+
+	role := "master"
+	if isReplica() {
+		role = "replica"
+	}
+
+	fields := []resp.Value{
+		resp.NewBulkString("server"), resp.NewBulkString("redis"),
+		resp.NewBulkString("version"), resp.NewBulkString("7.4.0"),
+		resp.NewBulkString("proto"), resp.NewInteger(int64(version)),
+		resp.NewBulkString("id"), resp.NewInteger(1),
+		resp.NewBulkString("mode"), resp.NewBulkString("standalone"),
+		resp.NewBulkString("role"), resp.NewBulkString(role),
+		resp.NewBulkString("modules"), resp.NewArray([]resp.Value{}...),
+	}
+	if version >= 3 {
+		return resp.FlatMap(fields)
+	}
+	return resp.NewArray(fields...)
+}
+
+// parseScanOptions parses the trailing [MATCH pattern] [COUNT n] [TYPE t]
+// options shared by SCAN/HSCAN/SSCAN/ZSCAN. count defaults to 10, matching
+// real Redis.
+func parseScanOptions(args []string) (match string, count int, typeFilter string, err error) {
+	count = 10
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			if i+1 >= len(args) {
+				return "", 0, "", fmt.Errorf("syntax error")
+			}
+			i++
+			match = args[i]
+		case "COUNT":
+			if i+1 >= len(args) {
+				return "", 0, "", fmt.Errorf("syntax error")
+			}
+			i++
+			count, err = strconv.Atoi(args[i])
+			if err != nil {
+				return "", 0, "", fmt.Errorf("value is not an integer or out of range")
+			}
+		case "TYPE":
+			if i+1 >= len(args) {
+				return "", 0, "", fmt.Errorf("syntax error")
+			}
+			i++
+			typeFilter = args[i]
+		default:
+			return "", 0, "", fmt.Errorf("syntax error")
+		}
+	}
+	return match, count, typeFilter, nil
+}
+
+func scanReply(cursor int64, items []string) resp.Value {
+	values := make([]resp.Value, len(items))
+	for i, item := range items {
+		values[i] = resp.NewBulkString(item)
+	}
+	return resp.NewArray(
+		resp.NewBulkString(strconv.FormatInt(cursor, 10)),
+		resp.NewArray(values...),
+	)
+}
+
+// allKeys returns every live key, lazily evicting any found expired along
+// the way. Keys and Scan both build on it.
+func (s *MemStore) allKeys() []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -276,7 +1004,7 @@ func getAllKeys(s *Store) []string {
 
 	for key, item := range s.data {
 		if item.hasExpiry && now.After(item.expiresAt) {
-			go s.deleteKey(key)
+			go s.Delete(key)
 			continue
 		}
 		keys = append(keys, key)
@@ -284,3 +1012,14 @@ func getAllKeys(s *Store) []string {
 
 	return keys
 }
+
+// Keys returns every live key matching the glob pattern.
+func (s *MemStore) Keys(patternStr string) []string {
+	matched := make([]string, 0)
+	for _, key := range s.allKeys() {
+		if pattern.Match(patternStr, key) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}